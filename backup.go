@@ -0,0 +1,399 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	backupFormatDir   = "dir"
+	backupFormatTarGz = "tar.gz"
+
+	backupRunIDLayout = "20060102T150405Z"
+	tarGzExtension    = ".tar.gz"
+	// notDeletedDir holds objects which were written to backup but not deleted. Underscore isn't allowed in
+	// namespace names, so it never clashes with a namespace directory
+	notDeletedDir = "_not-deleted"
+)
+
+var (
+	jobGroupKind = schema.GroupKind{Group: "batch", Kind: "Job"}
+	// generatedJobLabels are set by Job controller to UID of the Job
+	generatedJobLabels = []string{"controller-uid", "batch.kubernetes.io/controller-uid"}
+	// cleanerAnnotations are marks of quarantine and grace period
+	cleanerAnnotations = []string{
+		markedAtAnnotation, startedAtAnnotation, originalReplicasAnnotation, originalSuspendAnnotation, cancelledAtAnnotation,
+		orphanedSinceAnnotation, orphanedRunsAnnotation,
+	}
+)
+
+// Backup writes objects to directory or tarball before they are deleted, organised by run ID/namespace/kind/name.
+// Objects which weren't deleted after all are moved to not-deleted area of the run. Every entry of tarball is
+// a complete gzip member, so tarball stays readable if the run fails halfway
+type Backup struct {
+	Dir    string
+	RunID  string
+	Format string
+
+	file   *os.File
+	buffer bytes.Buffer
+	tar    *tar.Writer
+}
+
+// NewBackup creates backup of a run started at the given time
+func NewBackup(dir, format string, now time.Time) (*Backup, error) {
+	backup := &Backup{
+		Dir:    dir,
+		RunID:  now.UTC().Format(backupRunIDLayout),
+		Format: format,
+	}
+
+	switch format {
+	case backupFormatDir:
+		if err := os.MkdirAll(filepath.Join(dir, backup.RunID), 0755); err != nil {
+			return nil, errors.Wrap(err, "failed to create backup directory")
+		}
+	case backupFormatTarGz:
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, errors.Wrap(err, "failed to create backup directory")
+		}
+		file, err := os.Create(filepath.Join(dir, backup.RunID+tarGzExtension))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create backup tarball")
+		}
+		backup.file = file
+		backup.tar = tar.NewWriter(&backup.buffer)
+	default:
+		return nil, errors.Errorf("unknown backup format %s, supported formats are %s, %s", format, backupFormatDir, backupFormatTarGz)
+	}
+
+	return backup, nil
+}
+
+// Write writes the given object in the form it can be created again, nil backup writes nothing
+func (b *Backup) Write(object *unstructured.Unstructured) error {
+	if b == nil {
+		return nil
+	}
+
+	data, err := yaml.Marshal(backupObject(object).Object)
+	if err != nil {
+		return errors.Wrapf(err, "failed to encode backup of %s %s", object.GetKind(), object.GetName())
+	}
+
+	return errors.Wrapf(b.writeEntry(path.Join(b.RunID, backupEntry(object)), data), "failed to write backup of %s %s", object.GetKind(), object.GetName())
+}
+
+// Discard moves the given object written to backup to not-deleted area, so it isn't restored. Entries of tarball
+// can't be removed, so the object is written to not-deleted area of tarball again
+func (b *Backup) Discard(object *unstructured.Unstructured) error {
+	if b == nil {
+		return nil
+	}
+
+	entry := backupEntry(object)
+	if b.tar == nil {
+		file := filepath.Join(b.Dir, b.RunID, filepath.FromSlash(entry))
+		discarded := filepath.Join(b.Dir, b.RunID, notDeletedDir, filepath.FromSlash(entry))
+		if err := os.MkdirAll(filepath.Dir(discarded), 0755); err != nil {
+			return errors.Wrap(err, "failed to create backup directory")
+		}
+		return errors.Wrapf(os.Rename(file, discarded), "failed to discard backup of %s %s", object.GetKind(), object.GetName())
+	}
+
+	data, err := yaml.Marshal(backupObject(object).Object)
+	if err != nil {
+		return errors.Wrapf(err, "failed to encode backup of %s %s", object.GetKind(), object.GetName())
+	}
+
+	return errors.Wrapf(b.writeEntry(path.Join(b.RunID, notDeletedDir, entry), data), "failed to discard backup of %s %s", object.GetKind(), object.GetName())
+}
+
+// backupEntry returns path of the given object relative to run of backup
+func backupEntry(object *unstructured.Unstructured) string {
+	return path.Join(object.GetNamespace(), object.GetKind(), object.GetName()+".yaml")
+}
+
+// writeEntry writes data to file of the given name in backup directory or tarball
+func (b *Backup) writeEntry(name string, data []byte) error {
+	if b.tar == nil {
+		file := filepath.Join(b.Dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			return errors.Wrap(err, "failed to create backup directory")
+		}
+		return ioutil.WriteFile(file, data, 0644)
+	}
+
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}
+	if err := b.tar.WriteHeader(header); err != nil {
+		return err
+	}
+	if _, err := b.tar.Write(data); err != nil {
+		return err
+	}
+	if err := b.tar.Flush(); err != nil {
+		return err
+	}
+
+	return b.flush()
+}
+
+// flush writes buffered tar blocks to tarball as a complete gzip member
+func (b *Backup) flush() error {
+	writer := gzip.NewWriter(b.file)
+	if _, err := writer.Write(b.buffer.Bytes()); err != nil {
+		return err
+	}
+	b.buffer.Reset()
+
+	return writer.Close()
+}
+
+// backupObject returns copy of the given object without status, server populated metadata, owner references,
+// generated Job selector and marks of quarantine and grace period. Replicas and suspension changed by quarantine
+// are restored
+func backupObject(object *unstructured.Unstructured) *unstructured.Unstructured {
+	backup := &unstructured.Unstructured{Object: cleanObject(object.Object)}
+	backup.SetOwnerReferences(nil)
+
+	annotations := backup.GetAnnotations()
+	if replicas, err := strconv.ParseInt(annotations[originalReplicasAnnotation], 10, 64); err == nil {
+		unstructured.SetNestedField(backup.Object, replicas, "spec", "replicas")
+	}
+	if suspend, err := strconv.ParseBool(annotations[originalSuspendAnnotation]); err == nil {
+		unstructured.SetNestedField(backup.Object, suspend, "spec", "suspend")
+	}
+	removeKeys(backup.Object, cleanerAnnotations, "metadata", "annotations")
+	removeKeys(backup.Object, []string{quarantineLabel}, "metadata", "labels")
+
+	if backup.GroupVersionKind().GroupKind() == jobGroupKind {
+		if manual, _, _ := unstructured.NestedBool(backup.Object, "spec", "manualSelector"); !manual {
+			unstructured.RemoveNestedField(backup.Object, "spec", "selector")
+		}
+		removeKeys(backup.Object, generatedJobLabels, "metadata", "labels")
+		removeKeys(backup.Object, generatedJobLabels, "spec", "template", "metadata", "labels")
+	}
+
+	return backup
+}
+
+// removeKeys removes the given keys from nested map of object, the map is removed if nothing is left in it
+func removeKeys(object map[string]interface{}, keys []string, fields ...string) {
+	values, found, _ := unstructured.NestedMap(object, fields...)
+	if !found {
+		return
+	}
+
+	for _, key := range keys {
+		delete(values, key)
+	}
+	if len(values) == 0 {
+		unstructured.RemoveNestedField(object, fields...)
+		return
+	}
+	unstructured.SetNestedMap(object, values, fields...)
+}
+
+// WriteTyped writes the given typed object of the given API version and kind
+func (b *Backup) WriteTyped(object runtime.Object, apiVersion, kind string) error {
+	if b == nil {
+		return nil
+	}
+
+	converted, err := typedToUnstructured(object, apiVersion, kind)
+	if err != nil {
+		return err
+	}
+
+	return b.Write(converted)
+}
+
+// DiscardTyped moves the given typed object of the given API version and kind to not-deleted area
+func (b *Backup) DiscardTyped(object runtime.Object, apiVersion, kind string) error {
+	if b == nil {
+		return nil
+	}
+
+	converted, err := typedToUnstructured(object, apiVersion, kind)
+	if err != nil {
+		return err
+	}
+
+	return b.Discard(converted)
+}
+
+// typedToUnstructured returns unstructured copy of the given typed object of the given API version and kind
+func typedToUnstructured(object runtime.Object, apiVersion, kind string) (*unstructured.Unstructured, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(object)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to convert %s", kind)
+	}
+
+	converted := &unstructured.Unstructured{Object: content}
+	converted.SetAPIVersion(apiVersion)
+	converted.SetKind(kind)
+
+	return converted, nil
+}
+
+// Close flushes tarball of backup
+func (b *Backup) Close() error {
+	if b == nil || b.tar == nil {
+		return nil
+	}
+
+	if err := b.tar.Close(); err != nil {
+		return errors.Wrap(err, "failed to close backup tarball")
+	}
+	if err := b.flush(); err != nil {
+		return errors.Wrap(err, "failed to close backup tarball")
+	}
+
+	return errors.Wrap(b.file.Close(), "failed to close backup tarball")
+}
+
+// ReadBackup returns objects of the given run read from backup directory or tarball in install order. Objects in
+// not-deleted area of the run are left out
+func ReadBackup(dir, runID string) ([]*unstructured.Unstructured, error) {
+	var objects []*unstructured.Unstructured
+	entries := make(map[string]*unstructured.Unstructured)
+	discarded := make(map[string]bool)
+
+	decode := func(name string, data []byte) error {
+		entry := strings.TrimPrefix(filepath.ToSlash(name), runID+"/")
+		if strings.HasPrefix(entry, notDeletedDir+"/") {
+			discarded[strings.TrimPrefix(entry, notDeletedDir+"/")] = true
+			return nil
+		}
+
+		object := &unstructured.Unstructured{}
+		content, err := yaml.YAMLToJSON(data)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read backup %s", name)
+		}
+		if err := object.UnmarshalJSON(content); err != nil {
+			return errors.Wrapf(err, "failed to decode backup %s", name)
+		}
+		entries[entry] = object
+		return nil
+	}
+
+	tarball := filepath.Join(dir, runID+tarGzExtension)
+	if _, err := os.Stat(tarball); err == nil {
+		if err := readTarGz(tarball, decode); err != nil {
+			return nil, err
+		}
+	} else {
+		root := filepath.Join(dir, runID)
+		if _, err := os.Stat(root); err != nil {
+			return nil, errors.Wrapf(err, "failed to find backup of run %s", runID)
+		}
+		err := filepath.Walk(root, func(file string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() || !strings.HasSuffix(file, ".yaml") {
+				return err
+			}
+			data, err := ioutil.ReadFile(file)
+			if err != nil {
+				return errors.Wrapf(err, "failed to read backup %s", file)
+			}
+			name, err := filepath.Rel(dir, file)
+			if err != nil {
+				return errors.Wrapf(err, "failed to read backup %s", file)
+			}
+			return decode(name, data)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for entry, object := range entries {
+		if !discarded[entry] {
+			objects = append(objects, object)
+		}
+	}
+	SortInstallOrder(objects)
+
+	return objects, nil
+}
+
+// readTarGz calls read for every file of the given tarball
+func readTarGz(tarball string, read func(name string, data []byte) error) error {
+	file, err := os.Open(tarball)
+	if err != nil {
+		return errors.Wrap(err, "failed to open backup tarball")
+	}
+	defer file.Close()
+
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read backup tarball %s", tarball)
+	}
+	tarReader := tar.NewReader(gzipReader)
+
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to read backup tarball %s", tarball)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		data, err := ioutil.ReadAll(tarReader)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read backup tarball %s", tarball)
+		}
+		if err := read(header.Name, data); err != nil {
+			return err
+		}
+	}
+}
+
+// SortInstallOrder sorts objects in dependency order, e.g. ConfigMaps and Services before Deployments. Kinds
+// unknown to Helm install order (e.g. custom resources) go last
+func SortInstallOrder(objects []*unstructured.Unstructured) {
+	order := make(map[string]int, len(releaseutil.InstallOrder))
+	for i, kind := range releaseutil.InstallOrder {
+		order[kind] = i
+	}
+	rank := func(kind string) int {
+		if i, ok := order[kind]; ok {
+			return i
+		}
+		return len(order)
+	}
+
+	sort.SliceStable(objects, func(m, n int) bool {
+		first, second := rank(objects[m].GetKind()), rank(objects[n].GetKind())
+		if first != second {
+			return first < second
+		}
+		return KeyOf(objects[m]).String() < KeyOf(objects[n]).String()
+	})
+}
@@ -1,15 +1,26 @@
 package main
 
 import (
+	"strings"
+
 	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/restmapper"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
 // Client represents the wrapper of Kubernetes API client
 type Client struct {
 	clientConfig clientcmd.ClientConfig
+	context      string
 	clientset    kubernetes.Interface
+	dynamic      dynamic.Interface
+	mapper       meta.RESTMapper
 }
 
 // NewClient creates Client object using local kubecfg
@@ -28,9 +39,24 @@ func NewClient(kubeconfig, context string) (*Client, error) {
 		return nil, errors.Wrap(err, "failed to load clientset")
 	}
 
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load dynamic client")
+	}
+
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load discovery client")
+	}
+	cachedDiscovery := memory.NewMemCacheClient(discoveryClient)
+	mapper := restmapper.NewShortcutExpander(restmapper.NewDeferredDiscoveryRESTMapper(cachedDiscovery), cachedDiscovery)
+
 	return &Client{
 		clientConfig: clientConfig,
+		context:      context,
 		clientset:    clientset,
+		dynamic:      dynamicClient,
+		mapper:       mapper,
 	}, nil
 }
 
@@ -47,3 +73,45 @@ func (c *Client) NamespaceInConfig() (string, error) {
 
 	return rawConfig.Contexts[rawConfig.CurrentContext].Namespace, nil
 }
+
+// ContextName returns name of kubeconfig context used by client
+func (c *Client) ContextName() (string, error) {
+	if c.context != "" {
+		return c.context, nil
+	}
+	if c.clientConfig == nil {
+		return "", errors.New("clientConfig is not set")
+	}
+
+	rawConfig, err := c.clientConfig.RawConfig()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to load rawConfig")
+	}
+
+	return rawConfig.CurrentContext, nil
+}
+
+// ResolveKind returns REST mapping of the namespaced kind given as kind (Deployment), kind.group
+// (Certificate.cert-manager.io), resource (deployments), short name (deploy) or resource.group
+func (c *Client) ResolveKind(kind string) (*meta.RESTMapping, error) {
+	mapping, err := c.mapper.RESTMapping(schema.ParseGroupKind(kind))
+	if err != nil {
+		_, groupResource := schema.ParseResourceArg(strings.ToLower(kind))
+
+		gvk, kindErr := c.mapper.KindFor(groupResource.WithVersion(""))
+		if kindErr != nil {
+			return nil, errors.Wrapf(kindErr, "failed to resolve kind %s", kind)
+		}
+
+		mapping, err = c.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to resolve kind %s", kind)
+		}
+	}
+
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return nil, errors.Errorf("kind %s is not namespaced", mapping.GroupVersionKind.Kind)
+	}
+
+	return mapping, nil
+}
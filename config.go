@@ -0,0 +1,503 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	flag "github.com/spf13/pflag"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	configAPIVersion = "k8s-cleaner.io/v1alpha1"
+	configKind       = "CleanerConfig"
+	envPrefix        = "K8S_CLEANER_"
+)
+
+// Config represents declarative configuration of k8s-cleaner. Every setting can be overridden by flag
+// or K8S_CLEANER_* environment variable
+type Config struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+
+	Context          string      `json:"context,omitempty"`
+	DryRun           *DryRunMode `json:"dryRun,omitempty"`
+	Strict           *bool       `json:"strict,omitempty"`
+	Namespaces       []string    `json:"namespaces,omitempty"`
+	DefaultNamespace string      `json:"defaultNamespace,omitempty"`
+	Inventory        string      `json:"inventory,omitempty"`
+	BackupDir        string      `json:"backupDir,omitempty"`
+	BackupFormat     string      `json:"backupFormat,omitempty"`
+	ProtectSelector  string      `json:"protectSelector,omitempty"`
+	Selector         string      `json:"selector,omitempty"`
+	FieldSelector    string      `json:"fieldSelector,omitempty"`
+	MaxCount         *int64      `json:"maxCount,omitempty"`
+	HelmHistoryMax   *int64      `json:"helmHistoryMax,omitempty"`
+
+	// Grace period of pruning
+	MinAge      *metav1.Duration `json:"minAge,omitempty"`
+	OrphanGrace *metav1.Duration `json:"orphanGrace,omitempty"`
+	OrphanRuns  *int64           `json:"orphanRuns,omitempty"`
+
+	Quarantine      *bool            `json:"quarantine,omitempty"`
+	QuarantineGrace *metav1.Duration `json:"quarantineGrace,omitempty"`
+
+	ResolveOwners *bool `json:"resolveOwners,omitempty"`
+	// Detectors are names of ownership detectors of GitOps tools, empty list turns them off
+	Detectors []string `json:"detectors,omitempty"`
+
+	// Sources of manifests
+	Directories   []string    `json:"directories,omitempty"`
+	Kustomize     []string    `json:"kustomize,omitempty"`
+	Charts        []HelmChart `json:"charts,omitempty"`
+	GitRepository string      `json:"gitRepository,omitempty"`
+	GitRevision   string      `json:"gitRevision,omitempty"`
+	HelmReleases  *bool       `json:"helmReleases,omitempty"`
+
+	// RestrictedNamespaces are never cleaned
+	RestrictedNamespaces []string `json:"restrictedNamespaces,omitempty"`
+	// Kinds are cleaned when selected kind is All
+	Kinds []KindRule `json:"kinds,omitempty"`
+	// Protect lists objects which are never deleted in any namespace
+	Protect []ProtectRule `json:"protect,omitempty"`
+	// NamespaceRules override settings for particular namespaces
+	NamespaceRules []NamespaceRule `json:"namespaceRules,omitempty"`
+}
+
+// KindRule enables cleaning of a kind and sets rules for its objects
+type KindRule struct {
+	// Kind, short name or kind.group
+	Kind string `json:"kind"`
+	// Enabled is true by default, false disables kind enabled on upper level
+	Enabled *bool `json:"enabled,omitempty"`
+	// Protect lists objects of the kind which are never deleted
+	Protect []ProtectRule `json:"protect,omitempty"`
+	// Selector is a label selector restricting listed objects of the kind, in addition to --selector
+	Selector string `json:"selector,omitempty"`
+	// FieldSelector is a field selector restricting listed objects of the kind, in addition to --field-selector
+	FieldSelector string `json:"fieldSelector,omitempty"`
+}
+
+// NamespaceRule overrides settings for a namespace
+type NamespaceRule struct {
+	Namespace string `json:"namespace"`
+	// Kinds are merged with top-level kinds by kind
+	Kinds []KindRule `json:"kinds,omitempty"`
+	// Protect lists objects in namespace which are never deleted
+	Protect []ProtectRule `json:"protect,omitempty"`
+	// MaxCount is the number of Jobs to remain in namespace
+	MaxCount *int64 `json:"maxCount,omitempty"`
+	// Directories contain manifests without metadata.namespace targeting namespace
+	Directories []string `json:"directories,omitempty"`
+}
+
+// LoadConfig reads and validates configuration file, empty path returns empty configuration
+func LoadConfig(path string) (*Config, error) {
+	config := &Config{}
+	if path == "" {
+		return config, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read config")
+	}
+
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse config %s", path)
+	}
+
+	if errs := config.Validate(); len(errs) > 0 {
+		return nil, errors.Wrapf(errs.ToAggregate(), "invalid config %s", path)
+	}
+
+	return config, nil
+}
+
+// Validate returns all errors of configuration and compiles its protect rules
+func (c *Config) Validate() field.ErrorList {
+	var errs field.ErrorList
+
+	if c.APIVersion != configAPIVersion {
+		errs = append(errs, field.NotSupported(field.NewPath("apiVersion"), c.APIVersion, []string{configAPIVersion}))
+	}
+	if c.Kind != configKind {
+		errs = append(errs, field.NotSupported(field.NewPath("kind"), c.Kind, []string{configKind}))
+	}
+	if c.MaxCount != nil && *c.MaxCount < 0 {
+		errs = append(errs, field.Invalid(field.NewPath("maxCount"), *c.MaxCount, "must be greater than or equal to 0"))
+	}
+	if c.BackupFormat != "" && c.BackupFormat != backupFormatDir && c.BackupFormat != backupFormatTarGz {
+		errs = append(errs, field.NotSupported(field.NewPath("backupFormat"), c.BackupFormat, []string{backupFormatDir, backupFormatTarGz}))
+	}
+	if c.HelmHistoryMax != nil && *c.HelmHistoryMax < 0 {
+		errs = append(errs, field.Invalid(field.NewPath("helmHistoryMax"), *c.HelmHistoryMax, "must be greater than or equal to 0"))
+	}
+	if c.MinAge != nil && c.MinAge.Duration < 0 {
+		errs = append(errs, field.Invalid(field.NewPath("minAge"), c.MinAge.Duration.String(), "must be greater than or equal to 0"))
+	}
+	if c.OrphanGrace != nil && c.OrphanGrace.Duration < 0 {
+		errs = append(errs, field.Invalid(field.NewPath("orphanGrace"), c.OrphanGrace.Duration.String(), "must be greater than or equal to 0"))
+	}
+	if c.QuarantineGrace != nil && c.QuarantineGrace.Duration < 0 {
+		errs = append(errs, field.Invalid(field.NewPath("quarantineGrace"), c.QuarantineGrace.Duration.String(), "must be greater than or equal to 0"))
+	}
+	if c.OrphanRuns != nil && *c.OrphanRuns < 0 {
+		errs = append(errs, field.Invalid(field.NewPath("orphanRuns"), *c.OrphanRuns, "must be greater than or equal to 0"))
+	}
+	errs = append(errs, validateLabelSelector(c.ProtectSelector, field.NewPath("protectSelector"))...)
+	errs = append(errs, validateLabelSelector(c.Selector, field.NewPath("selector"))...)
+	errs = append(errs, validateFieldSelector(c.FieldSelector, field.NewPath("fieldSelector"))...)
+
+	for i, name := range c.Detectors {
+		if _, err := NewOwnershipDetectors([]string{name}); err != nil {
+			errs = append(errs, field.NotSupported(field.NewPath("detectors").Index(i), name, DetectorNames()))
+		}
+	}
+	errs = append(errs, validateNames(c.Namespaces, field.NewPath("namespaces"))...)
+	errs = append(errs, validateNames(c.RestrictedNamespaces, field.NewPath("restrictedNamespaces"))...)
+	errs = append(errs, validateKindRules(c.Kinds, field.NewPath("kinds"))...)
+	errs = append(errs, validateProtectRules(c.Protect, field.NewPath("protect"))...)
+
+	for i, chart := range c.Charts {
+		if chart.Path == "" {
+			errs = append(errs, field.Required(field.NewPath("charts").Index(i).Child("path"), ""))
+		}
+	}
+
+	namespaces := make(map[string]bool)
+	for i := range c.NamespaceRules {
+		rule := &c.NamespaceRules[i]
+		path := field.NewPath("namespaceRules").Index(i)
+
+		switch {
+		case rule.Namespace == "":
+			errs = append(errs, field.Required(path.Child("namespace"), ""))
+		case namespaces[rule.Namespace]:
+			errs = append(errs, field.Duplicate(path.Child("namespace"), rule.Namespace))
+		}
+		namespaces[rule.Namespace] = true
+
+		if rule.MaxCount != nil && *rule.MaxCount < 0 {
+			errs = append(errs, field.Invalid(path.Child("maxCount"), *rule.MaxCount, "must be greater than or equal to 0"))
+		}
+		errs = append(errs, validateKindRules(rule.Kinds, path.Child("kinds"))...)
+		errs = append(errs, validateProtectRules(rule.Protect, path.Child("protect"))...)
+	}
+
+	return errs
+}
+
+// validateNames returns errors of empty and duplicated names
+func validateNames(names []string, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	seen := make(map[string]bool)
+	for i, name := range names {
+		switch {
+		case name == "":
+			errs = append(errs, field.Required(path.Index(i), ""))
+		case seen[name]:
+			errs = append(errs, field.Duplicate(path.Index(i), name))
+		}
+		seen[name] = true
+	}
+
+	return errs
+}
+
+// validateKindRules returns errors of kind rules and compiles their protect rules
+func validateKindRules(rules []KindRule, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	kinds := make(map[string]bool)
+	for i := range rules {
+		rule := &rules[i]
+
+		switch {
+		case rule.Kind == "":
+			errs = append(errs, field.Required(path.Index(i).Child("kind"), ""))
+		case kinds[rule.Kind]:
+			errs = append(errs, field.Duplicate(path.Index(i).Child("kind"), rule.Kind))
+		}
+		kinds[rule.Kind] = true
+
+		errs = append(errs, validateProtectRules(rule.Protect, path.Index(i).Child("protect"))...)
+		errs = append(errs, validateLabelSelector(rule.Selector, path.Index(i).Child("selector"))...)
+		errs = append(errs, validateFieldSelector(rule.FieldSelector, path.Index(i).Child("fieldSelector"))...)
+	}
+
+	return errs
+}
+
+// validateLabelSelector returns error of invalid label selector
+func validateLabelSelector(selector string, path *field.Path) field.ErrorList {
+	if _, err := labels.Parse(selector); err != nil {
+		return field.ErrorList{field.Invalid(path, selector, err.Error())}
+	}
+
+	return nil
+}
+
+// validateFieldSelector returns error of invalid field selector
+func validateFieldSelector(selector string, path *field.Path) field.ErrorList {
+	if _, err := fields.ParseSelector(selector); err != nil {
+		return field.ErrorList{field.Invalid(path, selector, err.Error())}
+	}
+
+	return nil
+}
+
+// validateProtectRules returns errors of protect rules and compiles them
+func validateProtectRules(rules []ProtectRule, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	for i := range rules {
+		if err := rules[i].Compile(); err != nil {
+			errs = append(errs, field.Invalid(path.Index(i), rules[i].String(), err.Error()))
+		}
+	}
+
+	return errs
+}
+
+// ApplyToFlags sets flags which weren't set explicitly to values from configuration
+func (c *Config) ApplyToFlags(flags *flag.FlagSet) error {
+	values := make(map[string]string)
+
+	setString := func(name, value string) {
+		if value != "" {
+			values[name] = value
+		}
+	}
+	setSlice := func(name string, value []string) {
+		if len(value) > 0 {
+			values[name] = strings.Join(value, ",")
+		}
+	}
+
+	setString("context", c.Context)
+	setString("default-namespace", c.DefaultNamespace)
+	setString("inventory", c.Inventory)
+	setString("backup-dir", c.BackupDir)
+	setString("backup-format", c.BackupFormat)
+	setString("protect-selector", c.ProtectSelector)
+	setString("selector", c.Selector)
+	setString("field-selector", c.FieldSelector)
+	setString("git-repository", c.GitRepository)
+	setString("git-revision", c.GitRevision)
+	setSlice("namespaces", c.Namespaces)
+	setSlice("directories", c.AllDirectories())
+	setSlice("kustomize", c.Kustomize)
+	if c.DryRun != nil {
+		values["dry-run"] = string(*c.DryRun)
+	}
+	if c.Strict != nil {
+		values["strict"] = strconv.FormatBool(*c.Strict)
+	}
+	if c.MaxCount != nil {
+		values["max-count"] = strconv.FormatInt(*c.MaxCount, 10)
+	}
+	if c.HelmReleases != nil {
+		values["helm-releases"] = strconv.FormatBool(*c.HelmReleases)
+	}
+	if c.Detectors != nil {
+		values["detectors"] = strings.Join(c.Detectors, ",")
+	}
+	if c.ResolveOwners != nil {
+		values["resolve-owners"] = strconv.FormatBool(*c.ResolveOwners)
+	}
+	if c.HelmHistoryMax != nil {
+		values["helm-history-max"] = strconv.FormatInt(*c.HelmHistoryMax, 10)
+	}
+	if c.Quarantine != nil {
+		values["quarantine"] = strconv.FormatBool(*c.Quarantine)
+	}
+	if c.QuarantineGrace != nil {
+		values["quarantine-grace"] = c.QuarantineGrace.Duration.String()
+	}
+	if c.MinAge != nil {
+		values["min-age"] = c.MinAge.Duration.String()
+	}
+	if c.OrphanGrace != nil {
+		values["orphan-grace"] = c.OrphanGrace.Duration.String()
+	}
+	if c.OrphanRuns != nil {
+		values["orphan-runs"] = strconv.FormatInt(*c.OrphanRuns, 10)
+	}
+
+	for name, value := range values {
+		if flags.Changed(name) {
+			continue
+		}
+		if err := flags.Set(name, value); err != nil {
+			return errors.Wrapf(err, "invalid config value of %s", name)
+		}
+	}
+
+	return nil
+}
+
+// ApplyEnvironment sets flags which weren't set explicitly to values of K8S_CLEANER_* environment variables,
+// e.g. K8S_CLEANER_DRY_RUN for --dry-run
+func ApplyEnvironment(flags *flag.FlagSet) error {
+	var err error
+
+	flags.VisitAll(func(f *flag.Flag) {
+		if f.Changed || err != nil {
+			return
+		}
+
+		name := envPrefix + strings.ToUpper(strings.Replace(f.Name, "-", "_", -1))
+		if value, ok := os.LookupEnv(name); ok {
+			if setErr := flags.Set(f.Name, value); setErr != nil {
+				err = errors.Wrapf(setErr, "invalid value of %s", name)
+			}
+		}
+	})
+
+	return err
+}
+
+// AllDirectories returns top-level directories and directories of namespace rules
+func (c *Config) AllDirectories() []string {
+	directories := append([]string{}, c.Directories...)
+	for _, rule := range c.NamespaceRules {
+		directories = append(directories, rule.Directories...)
+	}
+
+	return directories
+}
+
+// DirectoryNamespaces returns namespaces of directories set in namespace rules
+func (c *Config) DirectoryNamespaces() map[string]string {
+	namespaces := make(map[string]string)
+	for _, rule := range c.NamespaceRules {
+		for _, directory := range rule.Directories {
+			namespaces[directory] = rule.Namespace
+		}
+	}
+
+	return namespaces
+}
+
+// NamespaceRule returns rule of the given namespace, nil if there is no rule
+func (c *Config) NamespaceRule(namespace string) *NamespaceRule {
+	for i := range c.NamespaceRules {
+		if c.NamespaceRules[i].Namespace == namespace {
+			return &c.NamespaceRules[i]
+		}
+	}
+
+	return nil
+}
+
+// KindRules returns rules of kinds cleaned in the given namespace when selected kind is All. Kinds of namespace
+// rule are merged with top-level kinds (or default kinds) by kind resolved by kindKey, disabled kinds are dropped
+func (c *Config) KindRules(namespace string, defaults []string, kindKey func(kind string) string) []KindRule {
+	rules := c.Kinds
+	if rules == nil {
+		for _, kind := range defaults {
+			rules = append(rules, KindRule{Kind: kind})
+		}
+	}
+
+	merged := append([]KindRule{}, rules...)
+	if rule := c.NamespaceRule(namespace); rule != nil {
+		for _, kindRule := range rule.Kinds {
+			found := false
+			for i := range merged {
+				if kindKey(merged[i].Kind) == kindKey(kindRule.Kind) {
+					merged[i] = mergeKindRules(merged[i], kindRule)
+					found = true
+				}
+			}
+			if !found {
+				merged = append(merged, kindRule)
+			}
+		}
+	}
+
+	var enabled []KindRule
+	for _, rule := range merged {
+		if rule.Enabled == nil || *rule.Enabled {
+			enabled = append(enabled, rule)
+		}
+	}
+
+	return enabled
+}
+
+// KindRule returns merged rule of the given kind in namespace, empty rule if there is no rule. Kinds of rules
+// are matched by kind resolved by kindKey, so e.g. deploy matches rule of Deployment
+func (c *Config) KindRule(namespace, kind string, kindKey func(kind string) string) KindRule {
+	rule := KindRule{Kind: kind}
+	for _, kindRule := range c.Kinds {
+		if kindKey(kindRule.Kind) == kindKey(kind) {
+			rule = mergeKindRules(rule, kindRule)
+		}
+	}
+
+	if namespaceRule := c.NamespaceRule(namespace); namespaceRule != nil {
+		for _, kindRule := range namespaceRule.Kinds {
+			if kindKey(kindRule.Kind) == kindKey(kind) {
+				rule = mergeKindRules(rule, kindRule)
+			}
+		}
+	}
+
+	return rule
+}
+
+// mergeKindRules returns rule with enabled flag of override, protect rules and selectors of both rules
+func mergeKindRules(base, override KindRule) KindRule {
+	merged := KindRule{
+		Kind:          base.Kind,
+		Enabled:       base.Enabled,
+		Protect:       append(append([]ProtectRule{}, base.Protect...), override.Protect...),
+		Selector:      JoinSelectors(base.Selector, override.Selector),
+		FieldSelector: JoinSelectors(base.FieldSelector, override.FieldSelector),
+	}
+	if override.Enabled != nil {
+		merged.Enabled = override.Enabled
+	}
+
+	return merged
+}
+
+// JoinSelectors returns selector matching all the given label or field selectors
+func JoinSelectors(selectors ...string) string {
+	var requirements []string
+	for _, selector := range selectors {
+		if selector != "" {
+			requirements = append(requirements, selector)
+		}
+	}
+
+	return strings.Join(requirements, ",")
+}
+
+// NamespaceProtect returns protect rules of the given namespace
+func (c *Config) NamespaceProtect(namespace string) []ProtectRule {
+	if rule := c.NamespaceRule(namespace); rule != nil {
+		return rule.Protect
+	}
+
+	return nil
+}
+
+// MaxCountFor returns the number of Jobs to remain in the given namespace
+func (c *Config) MaxCountFor(namespace string, maxCount int64) int64 {
+	if rule := c.NamespaceRule(namespace); rule != nil && rule.MaxCount != nil {
+		return *rule.MaxCount
+	}
+
+	return maxCount
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	flag "github.com/spf13/pflag"
+	"sigs.k8s.io/yaml"
+)
+
+// testKindKey resolves kinds the way cluster does for Deployment and its short name
+func testKindKey(kind string) string {
+	switch kind {
+	case "Deployment", "deploy", "deployments":
+		return "Deployment.apps"
+	}
+
+	return kind
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   []string
+	}{
+		{
+			name: "valid",
+			config: `
+apiVersion: k8s-cleaner.io/v1alpha1
+kind: CleanerConfig
+dryRun: false
+kinds:
+- kind: Deployment
+  protect:
+  - name: web
+namespaceRules:
+- namespace: payments
+  kinds:
+  - kind: Deployment
+    enabled: false
+`,
+		},
+		{
+			name:   "unknown apiVersion and kind",
+			config: "apiVersion: v1\nkind: ConfigMap\n",
+			want:   []string{"apiVersion", "kind"},
+		},
+		{
+			name: "invalid values",
+			config: `
+apiVersion: k8s-cleaner.io/v1alpha1
+kind: CleanerConfig
+maxCount: -1
+backupFormat: zip
+selector: "app in ("
+detectors: [helm, chef]
+`,
+			want: []string{"maxCount", "backupFormat", "selector", "detectors[1]"},
+		},
+		{
+			name: "invalid kind rules",
+			config: `
+apiVersion: k8s-cleaner.io/v1alpha1
+kind: CleanerConfig
+kinds:
+- kind: Deployment
+- kind: Deployment
+  protect:
+  - name: web
+    glob: web-*
+- protect:
+  - name: web
+`,
+			want: []string{"kinds[1].kind", "kinds[1].protect[0]", "kinds[2].kind"},
+		},
+		{
+			name: "invalid namespace rules",
+			config: `
+apiVersion: k8s-cleaner.io/v1alpha1
+kind: CleanerConfig
+namespaceRules:
+- namespace: payments
+  maxCount: -1
+- namespace: payments
+- kinds:
+  - kind: Service
+    fieldSelector: "metadata.name"
+`,
+			want: []string{"namespaceRules[0].maxCount", "namespaceRules[1].namespace", "namespaceRules[2].namespace", "namespaceRules[2].kinds[0].fieldSelector"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := &Config{}
+			if err := yaml.UnmarshalStrict([]byte(test.config), config); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var got []string
+			for _, err := range config.Validate() {
+				got = append(got, err.Field)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	client := dryRunClient
+	maxCount := int64(5)
+	config := &Config{
+		DryRun:     &client,
+		Namespaces: []string{"file"},
+		MaxCount:   &maxCount,
+	}
+
+	tests := []struct {
+		name   string
+		args   []string
+		env    map[string]string
+		config *Config
+		want   map[string]string
+	}{
+		{
+			name:   "defaults",
+			config: &Config{},
+			want:   map[string]string{"dry-run": "none", "namespaces": "[default]", "max-count": "10"},
+		},
+		{
+			name:   "file overrides defaults",
+			config: config,
+			want:   map[string]string{"dry-run": "client", "namespaces": "[file]", "max-count": "5"},
+		},
+		{
+			name:   "environment overrides file",
+			env:    map[string]string{envPrefix + "DRY_RUN": "server", envPrefix + "NAMESPACES": "env,other"},
+			config: config,
+			want:   map[string]string{"dry-run": "server", "namespaces": "[env,other]", "max-count": "5"},
+		},
+		{
+			name:   "flags override environment and file",
+			args:   []string{"--dry-run=false", "--namespaces=flag", "--max-count=1"},
+			env:    map[string]string{envPrefix + "DRY_RUN": "server", envPrefix + "MAX_COUNT": "3"},
+			config: config,
+			want:   map[string]string{"dry-run": "false", "namespaces": "[flag]", "max-count": "1"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			flags := flag.NewFlagSet("test", flag.ContinueOnError)
+			flags.String("dry-run", string(dryRunNone), "")
+			flags.StringSlice("namespaces", []string{"default"}, "")
+			flags.Int64("max-count", 10, "")
+			if err := flags.Parse(test.args); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			for name, value := range test.env {
+				os.Setenv(name, value)
+				defer os.Unsetenv(name)
+			}
+
+			if err := ApplyEnvironment(flags); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := test.config.ApplyToFlags(flags); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			for name, want := range test.want {
+				if got := flags.Lookup(name).Value.String(); got != want {
+					t.Errorf("got %s %s, want %s", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigKindRules(t *testing.T) {
+	disabled := false
+	enabled := true
+
+	tests := []struct {
+		name      string
+		config    Config
+		namespace string
+		want      []KindRule
+	}{
+		{
+			name:      "default kinds",
+			namespace: "default",
+			want:      []KindRule{{Kind: "Deployment"}, {Kind: jobsKind}},
+		},
+		{
+			name:      "top-level kinds replace default kinds",
+			config:    Config{Kinds: []KindRule{{Kind: "Service"}}},
+			namespace: "default",
+			want:      []KindRule{{Kind: "Service"}},
+		},
+		{
+			name: "namespace kinds are merged by resolved kind",
+			config: Config{
+				Kinds: []KindRule{{Kind: "Deployment", Selector: "team=payments", Protect: []ProtectRule{{Name: "web"}}}},
+				NamespaceRules: []NamespaceRule{{
+					Namespace: "payments",
+					Kinds:     []KindRule{{Kind: "deploy", Selector: "tier=backend", Protect: []ProtectRule{{Glob: "api-*"}}}, {Kind: "Service"}},
+				}},
+			},
+			namespace: "payments",
+			want: []KindRule{
+				{Kind: "Deployment", Selector: "team=payments,tier=backend", Protect: []ProtectRule{{Name: "web"}, {Glob: "api-*"}}},
+				{Kind: "Service"},
+			},
+		},
+		{
+			name: "namespace rules of other namespaces are ignored",
+			config: Config{
+				NamespaceRules: []NamespaceRule{{Namespace: "payments", Kinds: []KindRule{{Kind: "Service"}}}},
+			},
+			namespace: "default",
+			want:      []KindRule{{Kind: "Deployment"}, {Kind: jobsKind}},
+		},
+		{
+			name: "namespace rule disables kind",
+			config: Config{
+				NamespaceRules: []NamespaceRule{{Namespace: "payments", Kinds: []KindRule{{Kind: "deployments", Enabled: &disabled}}}},
+			},
+			namespace: "payments",
+			want:      []KindRule{{Kind: jobsKind}},
+		},
+		{
+			name: "namespace rule enables kind disabled on top level",
+			config: Config{
+				Kinds:          []KindRule{{Kind: "Deployment", Enabled: &disabled, Protect: []ProtectRule{{Name: "web"}}}, {Kind: "Service"}},
+				NamespaceRules: []NamespaceRule{{Namespace: "payments", Kinds: []KindRule{{Kind: "Deployment", Enabled: &enabled}}}},
+			},
+			namespace: "payments",
+			want:      []KindRule{{Kind: "Deployment", Enabled: &enabled, Protect: []ProtectRule{{Name: "web"}}}, {Kind: "Service"}},
+		},
+		{
+			name:      "disabled top-level kind",
+			config:    Config{Kinds: []KindRule{{Kind: "Deployment", Enabled: &disabled}, {Kind: "Service"}}},
+			namespace: "default",
+			want:      []KindRule{{Kind: "Service"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.config.KindRules(test.namespace, []string{"Deployment", jobsKind}, testKindKey)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestConfigKindRule(t *testing.T) {
+	config := Config{
+		Kinds: []KindRule{
+			{Kind: "Deployment", Protect: []ProtectRule{{Name: "web"}}, FieldSelector: "metadata.name!=api"},
+			{Kind: "Service", Protect: []ProtectRule{{Name: "kubernetes"}}},
+		},
+		NamespaceRules: []NamespaceRule{{
+			Namespace: "payments",
+			Kinds:     []KindRule{{Kind: "deploy", Selector: "team=payments", Protect: []ProtectRule{{Regex: "^db-"}}}},
+		}},
+	}
+
+	tests := []struct {
+		name      string
+		namespace string
+		kind      string
+		want      KindRule
+	}{
+		{
+			name:      "kind without rules",
+			namespace: "default",
+			kind:      "StatefulSet",
+			want:      KindRule{Kind: "StatefulSet"},
+		},
+		{
+			name:      "top-level rule",
+			namespace: "default",
+			kind:      "Deployment",
+			want:      KindRule{Kind: "Deployment", Protect: []ProtectRule{{Name: "web"}}, FieldSelector: "metadata.name!=api"},
+		},
+		{
+			name:      "short name matches rule of kind",
+			namespace: "default",
+			kind:      "deploy",
+			want:      KindRule{Kind: "deploy", Protect: []ProtectRule{{Name: "web"}}, FieldSelector: "metadata.name!=api"},
+		},
+		{
+			name:      "namespace rule is merged",
+			namespace: "payments",
+			kind:      "deployments",
+			want: KindRule{
+				Kind:          "deployments",
+				Protect:       []ProtectRule{{Name: "web"}, {Regex: "^db-"}},
+				Selector:      "team=payments",
+				FieldSelector: "metadata.name!=api",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := config.KindRule(test.namespace, test.kind, testKindKey)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
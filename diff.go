@@ -0,0 +1,341 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/pkg/errors"
+	"github.com/pmezard/go-difflib/difflib"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	diffOutputUnified    = "unified"
+	diffOutputStructured = "structured"
+)
+
+// serverMetadataFields are populated by API server and never compared
+var serverMetadataFields = []string{"managedFields", "resourceVersion", "uid", "creationTimestamp", "generation", "selfLink"}
+
+// FieldChange represents a field which differs between VCS and cluster
+type FieldChange struct {
+	Path    string      `json:"path"`
+	VCS     interface{} `json:"vcs,omitempty"`
+	Cluster interface{} `json:"cluster,omitempty"`
+}
+
+// ObjectDiff represents spec-level drift of object existing in VCS and cluster
+type ObjectDiff struct {
+	Namespace string        `json:"namespace"`
+	Kind      string        `json:"kind"`
+	Name      string        `json:"name"`
+	Location  string        `json:"location"`
+	Changes   []FieldChange `json:"changes"`
+
+	desired map[string]interface{}
+	actual  map[string]interface{}
+}
+
+// DiffObject compares the given manifest with the live object projected onto fields set in manifest. Server
+// populated fields are ignored and quantities are compared by value, so defaults and status never differ
+func DiffObject(manifest *Manifest, live *unstructured.Unstructured) *ObjectDiff {
+	desired := cleanObject(manifest.Object.Object)
+	actual, _ := project(desired, cleanObject(live.Object)).(map[string]interface{})
+
+	diff := &ObjectDiff{
+		Namespace: live.GetNamespace(),
+		Kind:      live.GetKind(),
+		Name:      live.GetName(),
+		Location:  manifest.Location.String(),
+		desired:   desired,
+		actual:    actual,
+	}
+	diffValues("", desired, actual, &diff.Changes)
+
+	return diff
+}
+
+// Drifted returns whether live object differs from manifest
+func (d *ObjectDiff) Drifted() bool {
+	return len(d.Changes) > 0
+}
+
+// Unified returns unified diff of manifest and live object in YAML
+func (d *ObjectDiff) Unified() (string, error) {
+	desired, err := yaml.Marshal(d.desired)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to encode manifest")
+	}
+	actual, err := yaml.Marshal(d.actual)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to encode live object")
+	}
+
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(desired)),
+		B:        difflib.SplitLines(string(actual)),
+		FromFile: "vcs " + d.Location,
+		ToFile:   fmt.Sprintf("cluster %s/%s/%s", d.Namespace, d.Kind, d.Name),
+		Context:  3,
+	})
+}
+
+// Print prints diff in the given output format
+func (d *ObjectDiff) Print(output string) error {
+	if output == diffOutputStructured {
+		data, err := json.Marshal(d)
+		if err != nil {
+			return errors.Wrap(err, "failed to encode diff")
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	unified, err := d.Unified()
+	if err != nil {
+		return err
+	}
+	color.Cyan("%s %s differs from VCS", d.Kind, d.Name)
+	for _, line := range difflib.SplitLines(unified) {
+		switch {
+		case strings.HasPrefix(line, "+++") || strings.HasPrefix(line, "---"):
+			fmt.Print(line)
+		case strings.HasPrefix(line, "+"):
+			color.New(color.FgGreen).Print(line)
+		case strings.HasPrefix(line, "-"):
+			color.New(color.FgRed).Print(line)
+		default:
+			fmt.Print(line)
+		}
+	}
+
+	return nil
+}
+
+// KindDiff compares objects of the given kind in namespace existing in VCS and cluster, prints drifted objects
+// and returns whether drift was found
+func (c *Client) KindDiff(namespace string, mapping *meta.RESTMapping, opts CleanerOptions, output string) (bool, error) {
+	clusterObjects, err := c.ListObjects(namespace, mapping, opts.ListOptions())
+	if err != nil {
+		return false, err
+	}
+
+	drifted := false
+	for _, object := range clusterObjects.Items {
+		manifest := opts.Manifests.Get(KeyOf(&object))
+		if manifest == nil {
+			continue
+		}
+
+		diff := DiffObject(manifest, &object)
+		if !diff.Drifted() {
+			continue
+		}
+		drifted = true
+		if err := diff.Print(output); err != nil {
+			return false, err
+		}
+	}
+
+	return drifted, nil
+}
+
+// cleanObject returns copy of the given object without status and server populated metadata
+func cleanObject(object map[string]interface{}) map[string]interface{} {
+	cleaned := deepCopyObject(object)
+	delete(cleaned, "status")
+	if metadata, ok := cleaned["metadata"].(map[string]interface{}); ok {
+		for _, field := range serverMetadataFields {
+			delete(metadata, field)
+		}
+	}
+
+	return cleaned
+}
+
+// deepCopyObject returns deep copy of JSON compatible map
+func deepCopyObject(object map[string]interface{}) map[string]interface{} {
+	return (&unstructured.Unstructured{Object: object}).DeepCopy().Object
+}
+
+// project returns live value reduced to fields set in desired value. Lists of named items are matched by name,
+// live items absent in desired list are kept as is
+func project(desired, live interface{}) interface{} {
+	switch desiredValue := desired.(type) {
+	case map[string]interface{}:
+		liveValue, ok := live.(map[string]interface{})
+		if !ok {
+			return live
+		}
+		projected := make(map[string]interface{}, len(desiredValue))
+		for key, value := range desiredValue {
+			if liveField, ok := liveValue[key]; ok {
+				projected[key] = project(value, liveField)
+			}
+		}
+		return projected
+	case []interface{}:
+		liveValue, ok := live.([]interface{})
+		if !ok {
+			return live
+		}
+		return projectList(desiredValue, liveValue)
+	default:
+		if quantitiesEqual(desired, live) {
+			return desired
+		}
+		return live
+	}
+}
+
+// projectList projects items of live list onto items of desired list
+func projectList(desired, live []interface{}) []interface{} {
+	projected := make([]interface{}, 0, len(live))
+
+	if !namedItems(desired) || !namedItems(live) {
+		for i, item := range live {
+			if i < len(desired) {
+				item = project(desired[i], item)
+			}
+			projected = append(projected, item)
+		}
+		return projected
+	}
+
+	liveItems := make(map[string]interface{}, len(live))
+	for _, item := range live {
+		liveItems[itemName(item)] = item
+	}
+	desiredNames := make(map[string]bool, len(desired))
+	for _, item := range desired {
+		desiredNames[itemName(item)] = true
+		if liveItem, ok := liveItems[itemName(item)]; ok {
+			projected = append(projected, project(item, liveItem))
+		}
+	}
+	for _, item := range live {
+		if !desiredNames[itemName(item)] {
+			projected = append(projected, item)
+		}
+	}
+
+	return projected
+}
+
+// namedItems returns whether all items of the list are objects with name
+func namedItems(items []interface{}) bool {
+	for _, item := range items {
+		if itemName(item) == "" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// itemName returns name of list item, empty string if item isn't an object with name
+func itemName(item interface{}) string {
+	object, ok := item.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	name, _ := object["name"].(string)
+
+	return name
+}
+
+// quantitiesEqual returns whether both values are equal quantities, e.g. 1Gi and 1024Mi or 1 and "1"
+func quantitiesEqual(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return false
+	}
+
+	first, err := resource.ParseQuantity(fmt.Sprint(a))
+	if err != nil {
+		return false
+	}
+	second, err := resource.ParseQuantity(fmt.Sprint(b))
+	if err != nil {
+		return false
+	}
+
+	return first.Cmp(second) == 0
+}
+
+// diffValues appends changes between desired and projected live values to changes
+func diffValues(path string, desired, actual interface{}, changes *[]FieldChange) {
+	desiredMap, desiredIsMap := desired.(map[string]interface{})
+	actualMap, actualIsMap := actual.(map[string]interface{})
+	if desiredIsMap && actualIsMap {
+		var keys []string
+		for key := range desiredMap {
+			keys = append(keys, key)
+		}
+		for key := range actualMap {
+			if _, ok := desiredMap[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			diffValues(joinPath(path, key), desiredMap[key], actualMap[key], changes)
+		}
+		return
+	}
+
+	desiredList, desiredIsList := desired.([]interface{})
+	actualList, actualIsList := actual.([]interface{})
+	if desiredIsList && actualIsList && namedItems(desiredList) && namedItems(actualList) {
+		actualItems := make(map[string]interface{}, len(actualList))
+		for _, item := range actualList {
+			actualItems[itemName(item)] = item
+		}
+		desiredNames := make(map[string]bool, len(desiredList))
+		for i, item := range desiredList {
+			desiredNames[itemName(item)] = true
+			diffValues(itemPath(path, i, item), item, actualItems[itemName(item)], changes)
+		}
+		for i, item := range actualList {
+			if !desiredNames[itemName(item)] {
+				diffValues(itemPath(path, i, item), nil, item, changes)
+			}
+		}
+		return
+	}
+	if desiredIsList && actualIsList && len(desiredList) == len(actualList) {
+		for i := range desiredList {
+			diffValues(itemPath(path, i, desiredList[i]), desiredList[i], actualList[i], changes)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(desired, actual) {
+		*changes = append(*changes, FieldChange{Path: path, VCS: desired, Cluster: actual})
+	}
+}
+
+// joinPath returns path of the given field
+func joinPath(path, field string) string {
+	if path == "" {
+		return field
+	}
+
+	return path + "." + field
+}
+
+// itemPath returns path of list item, by name if item has name
+func itemPath(path string, index int, item interface{}) string {
+	if name := itemName(item); name != "" {
+		return fmt.Sprintf("%s[name=%s]", path, name)
+	}
+
+	return fmt.Sprintf("%s[%d]", path, index)
+}
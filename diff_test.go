@@ -0,0 +1,185 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+func TestDiffObject(t *testing.T) {
+	const manifest = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: default
+spec:
+  replicas: 2
+  template:
+    spec:
+      containers:
+      - name: app
+        image: app:1
+        resources:
+          limits:
+            memory: 1Gi
+      - name: proxy
+        image: proxy:1
+`
+
+	tests := []struct {
+		name string
+		live string
+		want []FieldChange
+	}{
+		{
+			name: "defaults, status and server fields are ignored",
+			live: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: default
+  uid: 6d1d3a8e
+  resourceVersion: "42"
+  generation: 3
+spec:
+  replicas: 2
+  progressDeadlineSeconds: 600
+  template:
+    spec:
+      containers:
+      - name: app
+        image: app:1
+        imagePullPolicy: IfNotPresent
+        resources:
+          limits:
+            memory: 1Gi
+      - name: proxy
+        image: proxy:1
+status:
+  replicas: 2
+`,
+		},
+		{
+			name: "quantities are compared by value",
+			live: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: default
+spec:
+  replicas: 2
+  template:
+    spec:
+      containers:
+      - name: app
+        image: app:1
+        resources:
+          limits:
+            memory: 1024Mi
+      - name: proxy
+        image: proxy:1
+`,
+		},
+		{
+			name: "named items are matched by name",
+			live: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: default
+spec:
+  replicas: 2
+  template:
+    spec:
+      containers:
+      - name: proxy
+        image: proxy:1
+      - name: app
+        image: app:1
+        resources:
+          limits:
+            memory: 1Gi
+`,
+		},
+		{
+			name: "changed fields are reported by path",
+			live: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: default
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: app
+        image: app:2
+        resources:
+          limits:
+            memory: 2Gi
+      - name: proxy
+        image: proxy:1
+`,
+			want: []FieldChange{
+				{Path: "spec.replicas", VCS: float64(2), Cluster: float64(3)},
+				{Path: "spec.template.spec.containers[name=app].image", VCS: "app:1", Cluster: "app:2"},
+				{Path: "spec.template.spec.containers[name=app].resources.limits.memory", VCS: "1Gi", Cluster: "2Gi"},
+			},
+		},
+		{
+			name: "added and removed items are reported",
+			live: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: default
+spec:
+  replicas: 2
+  template:
+    spec:
+      containers:
+      - name: app
+        image: app:1
+        resources:
+          limits:
+            memory: 1Gi
+      - name: debug
+        image: busybox
+`,
+			want: []FieldChange{
+				{Path: "spec.template.spec.containers[name=proxy]", VCS: map[string]interface{}{"name": "proxy", "image": "proxy:1"}},
+				{Path: "spec.template.spec.containers[name=debug]", Cluster: map[string]interface{}{"name": "debug", "image": "busybox"}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			diff := DiffObject(&Manifest{Object: decodeObject(t, manifest)}, decodeObject(t, test.live))
+			if !reflect.DeepEqual(diff.Changes, test.want) {
+				t.Errorf("got %#v, want %#v", diff.Changes, test.want)
+			}
+			if diff.Drifted() != (len(test.want) > 0) {
+				t.Errorf("got drifted %v, want %v", diff.Drifted(), len(test.want) > 0)
+			}
+		})
+	}
+}
+
+func decodeObject(t *testing.T, data string) *unstructured.Unstructured {
+	object := &unstructured.Unstructured{}
+	if err := yaml.Unmarshal([]byte(data), &object.Object); err != nil {
+		t.Fatalf("failed to decode object: %v", err)
+	}
+
+	return object
+}
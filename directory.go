@@ -1,116 +1,132 @@
 package main
 
 import (
-	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
-	"regexp"
+	"runtime"
 	"strings"
+	"sync"
 
-	"github.com/fatih/color"
-	appsv1 "k8s.io/api/apps/v1"
-	v1beta1 "k8s.io/api/batch/v1beta1"
-	corev1 "k8s.io/api/core/v1"
-	"k8s.io/client-go/kubernetes/scheme"
+	"github.com/pkg/errors"
 )
 
-// CollectObjectsFromDir scans all the files in a directory (including sub-directories), parse yaml|yml manifests
-// and collect present objects and their names to map
-func CollectObjectsFromDir(directories []string) (map[string][]string, error) {
-	resourceMap := make(map[string][]string)
+// ManifestDirs describes directories with manifests and namespaces their objects are applied to
+type ManifestDirs struct {
+	// Paths to directories with manifests
+	Paths []string
+	// DefaultNamespace is used for manifests without metadata.namespace
+	DefaultNamespace string
+	// Namespaces maps directory to namespace used for manifests without metadata.namespace in it
+	Namespaces map[string]string
+}
+
+// NamespaceFor returns namespace for manifests without metadata.namespace in the given file.
+// The longest directory from Namespaces mapping containing the file wins
+func (d ManifestDirs) NamespaceFor(path string) string {
+	namespace := d.DefaultNamespace
+	matched := -1
 
-	for _, directory := range directories {
+	for directory, ns := range d.Namespaces {
+		directory = filepath.Clean(directory)
+		if path != directory && !strings.HasPrefix(path, directory+string(filepath.Separator)) {
+			continue
+		}
+		if len(directory) > matched {
+			matched = len(directory)
+			namespace = ns
+		}
+	}
+
+	return namespace
+}
+
+// isManifestFile returns whether the given file is a manifest judging by its extension
+func isManifestFile(path string) bool {
+	ext := filepath.Ext(path)
+	return ext == ".yaml" || ext == ".yml" || ext == ".json"
+}
 
+// CollectObjectsFromDir scans all the files in a directory (including sub-directories), parse yaml|yml|json manifests
+// of any kind and puts present objects to index. Files are parsed concurrently
+func CollectObjectsFromDir(dirs ManifestDirs, index *ManifestIndex) error {
+	var files []string
+
+	for _, directory := range dirs.Paths {
 		err := filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
-			if info.IsDir() {
-				return nil
+			if err != nil {
+				return err
 			}
 
-			var ext string
-			if ext = filepath.Ext(path); ext != ".yaml" && ext != ".yml" {
+			if info.IsDir() {
 				return nil
 			}
 
-			acceptedK8sTypes := regexp.MustCompile(acceptedK8sKinds)
-			fileAsString, err := ioutil.ReadFile(path)
-			if err != nil {
-				color.Red(fmt.Sprintf("Error while reading YAML manifest. Err was: %s", err))
-			}
-			sepYamlfiles := strings.Split(string(fileAsString), "---")
-
-			for _, file := range sepYamlfiles {
-				if file == "\n" || file == "" {
-					// ignore empty cases
-					continue
-				}
-
-				decode := scheme.Codecs.UniversalDeserializer().Decode
-				obj, groupVersionKind, err := decode([]byte(file), nil, nil)
-				if err != nil {
-					if debug {
-						color.Red(fmt.Sprintf("Error while decoding YAML object. Err was: %s", err))
-					}
-					continue
-				}
-
-				if !acceptedK8sTypes.MatchString(groupVersionKind.Kind) {
-					if debug {
-						color.Cyan("Skipping object with type: %s", groupVersionKind.Kind)
-					}
-				} else {
-
-					switch obj.(type) {
-					case *appsv1.Deployment:
-						resourceMap[groupVersionKind.Kind] = append(resourceMap[groupVersionKind.Kind], obj.(*appsv1.Deployment).ObjectMeta.Name)
-					case *corev1.Service:
-						resourceMap[groupVersionKind.Kind] = append(resourceMap[groupVersionKind.Kind], obj.(*corev1.Service).ObjectMeta.Name)
-					case *appsv1.StatefulSet:
-						resourceMap[groupVersionKind.Kind] = append(resourceMap[groupVersionKind.Kind], obj.(*appsv1.StatefulSet).ObjectMeta.Name)
-					case *v1beta1.CronJob:
-						resourceMap[groupVersionKind.Kind] = append(resourceMap[groupVersionKind.Kind], obj.(*v1beta1.CronJob).ObjectMeta.Name)
-					case *corev1.LimitRange:
-						resourceMap[groupVersionKind.Kind] = append(resourceMap[groupVersionKind.Kind], obj.(*corev1.LimitRange).ObjectMeta.Name)
-					case *appsv1.DaemonSet:
-						resourceMap[groupVersionKind.Kind] = append(resourceMap[groupVersionKind.Kind], obj.(*appsv1.DaemonSet).ObjectMeta.Name)
-					default:
-						continue
-					}
-				}
+			if !isManifestFile(path) {
+				return nil
 			}
 
+			files = append(files, filepath.Clean(path))
 			return nil
 		})
 		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
-			os.Exit(1)
+			return errors.Wrapf(err, "failed to scan directory %s", directory)
 		}
 	}
 
-	return resourceMap, nil
-}
+	paths := make(chan string)
+	var wg sync.WaitGroup
 
-// Except returns a new slice, containing all items that are in the left slice (k8scluster) but not the right slice (VCS)
-func Except(left []string, kind string, directories []string) []string {
-	var right []string
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				collectObjectsFromFile(path, dirs.NamespaceFor(path), index)
+			}
+		}()
+	}
+
+	for _, path := range files {
+		paths <- path
+	}
+	close(paths)
+	wg.Wait()
 
-	directoryObjects, err := CollectObjectsFromDir(directories)
+	return nil
+}
+
+// collectObjectsFromFile parses manifests in the given file and puts present objects to index
+func collectObjectsFromFile(path, namespace string, index *ManifestIndex) {
+	fileAsString, err := ioutil.ReadFile(path)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		index.AddError(Location{Source: path}, err)
+		return
 	}
-	for _, value := range directoryObjects[kind] {
-		right = append(right, value)
+
+	collectObjects(fileAsString, namespace, path, index)
+}
+
+// collectObjects parses multi-document YAML or JSON stream and puts present objects to index
+func collectObjects(content []byte, namespace, source string, index *ManifestIndex) {
+	documents, err := splitDocuments(content)
+	if err != nil {
+		index.AddError(Location{Source: source}, err)
+		return
 	}
 
-	for i := len(left) - 1; i >= 0; i-- {
-		for _, vD := range right {
-			if left[i] == vD {
-				left = append(left[:i], left[i+1:]...)
-				break
-			}
+	for i, document := range documents {
+		location := Location{Source: source, Line: document.Line, Document: i}
+
+		objects, err := decodeDocument(document.Data)
+		if err != nil {
+			location.Line = errorLine(err, document.Line)
+			index.AddError(location, err)
+			continue
 		}
-	}
 
-	return left
+		for _, obj := range objects {
+			index.Add(obj, namespace, location)
+		}
+	}
 }
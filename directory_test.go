@@ -0,0 +1,38 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestNamespaceFor(t *testing.T) {
+	dirs := ManifestDirs{
+		DefaultNamespace: "default",
+		Namespaces: map[string]string{
+			"manifests":             "apps",
+			"manifests/monitoring/": "monitoring",
+			"manifests/monitor":     "other",
+		},
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "manifests/app.yaml", want: "apps"},
+		{path: "manifests/monitoring/prometheus.yaml", want: "monitoring"},
+		{path: "manifests/monitoring/rules/alerts.yaml", want: "monitoring"},
+		{path: "manifests/monitoring-extra/app.yaml", want: "apps"},
+		{path: "manifests/monitoring", want: "monitoring"},
+		{path: "other/app.yaml", want: "default"},
+		{path: "manifests-old/app.yaml", want: "default"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			if got := dirs.NamespaceFor(filepath.FromSlash(test.path)); got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	yamlSeparator   = "---"
+	yamlDocumentEnd = "..."
+)
+
+// Location points to a document in a source of manifests
+type Location struct {
+	// Source is file, template or kustomization the document was read from
+	Source string
+	// Line is the first line of document in source starting from 1, 0 if unknown
+	Line int
+	// Document is the number of document in source starting from 0
+	Document int
+}
+
+// String returns location in source:line form
+func (l Location) String() string {
+	if l.Line == 0 {
+		return fmt.Sprintf("%s (document %d)", l.Source, l.Document)
+	}
+	return fmt.Sprintf("%s:%d (document %d)", l.Source, l.Line, l.Document)
+}
+
+// Less returns whether location goes before the other one
+func (l Location) Less(other Location) bool {
+	if l.Source != other.Source {
+		return l.Source < other.Source
+	}
+	if l.Line != other.Line {
+		return l.Line < other.Line
+	}
+	return l.Document < other.Document
+}
+
+// yamlErrorLine matches line number in errors of YAML parser
+var yamlErrorLine = regexp.MustCompile(`line (\d+)`)
+
+// errorLine returns line of source the error of document starting at the given line points to
+func errorLine(err error, start int) int {
+	match := yamlErrorLine.FindStringSubmatch(err.Error())
+	if match == nil {
+		return start
+	}
+
+	line, convErr := strconv.Atoi(match[1])
+	if convErr != nil || line == 0 {
+		return start
+	}
+
+	return start + line - 1
+}
+
+// document represents a single document of multi-document YAML or JSON stream
+type document struct {
+	Data []byte
+	// Line is the first line of document starting from 1
+	Line int
+}
+
+// splitDocuments splits multi-document YAML or stream of JSON objects into documents
+func splitDocuments(content []byte) ([]document, error) {
+	if trimmed := bytes.TrimSpace(content); len(trimmed) > 0 && trimmed[0] == '{' {
+		return splitJSONDocuments(content)
+	}
+
+	return splitYAMLDocuments(content)
+}
+
+// splitYAMLDocuments splits YAML by document separators and document end markers placed at the beginning of line,
+// so "---" inside block scalars and quoted strings never splits documents
+func splitYAMLDocuments(content []byte) ([]document, error) {
+	var (
+		documents []document
+		current   bytes.Buffer
+		start     = 1
+		line      = 0
+	)
+
+	flush := func(next int) {
+		if len(bytes.TrimSpace(current.Bytes())) > 0 {
+			data := make([]byte, current.Len())
+			copy(data, current.Bytes())
+			documents = append(documents, document{Data: data, Line: start})
+		}
+		current.Reset()
+		start = next
+	}
+
+	reader := bufio.NewReader(bytes.NewReader(content))
+	for {
+		text, err := reader.ReadBytes('\n')
+		if len(text) > 0 {
+			line++
+			trimmed := bytes.TrimRight(text, " \t\r\n")
+			switch {
+			case bytes.Equal(trimmed, []byte(yamlDocumentEnd)):
+				flush(line + 1)
+			case bytes.HasPrefix(trimmed, []byte(yamlSeparator)) &&
+				(len(trimmed) == len(yamlSeparator) || trimmed[len(yamlSeparator)] == ' ' || trimmed[len(yamlSeparator)] == '\t'):
+				// content after separator (e.g. "--- |") belongs to the next document, comments are dropped
+				flush(line + 1)
+				if rest := bytes.TrimSpace(text[len(yamlSeparator):]); len(rest) > 0 && rest[0] != '#' {
+					start = line
+					current.Write(text[len(yamlSeparator):])
+				}
+			default:
+				if current.Len() == 0 && len(bytes.TrimSpace(text)) == 0 {
+					start = line + 1
+					continue
+				}
+				current.Write(text)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read YAML document")
+		}
+	}
+	flush(line + 1)
+
+	return documents, nil
+}
+
+// splitJSONDocuments splits stream of JSON objects into documents
+func splitJSONDocuments(content []byte) ([]document, error) {
+	var documents []document
+
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	offset := 0
+	for {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "failed to read JSON document after line %d", bytes.Count(content[:offset], []byte("\n"))+1)
+		}
+
+		// RawMessage keeps original bytes, so its position in content points to the first line of document
+		if i := bytes.Index(content[offset:], raw); i >= 0 {
+			offset += i
+		}
+		documents = append(documents, document{Data: raw, Line: bytes.Count(content[:offset], []byte("\n")) + 1})
+		offset += len(raw)
+	}
+
+	return documents, nil
+}
+
+// decodeDocument decodes YAML or JSON document as objects of any kind. Items of List (e.g. v1/List) are returned
+// as separate objects, empty document returns no objects
+func decodeDocument(data []byte) ([]*unstructured.Unstructured, error) {
+	jsonData, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse document")
+	}
+	if trimmed := bytes.TrimSpace(jsonData); len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")) {
+		return nil, nil
+	}
+
+	obj := &unstructured.Unstructured{}
+	if _, _, err := unstructured.UnstructuredJSONScheme.Decode(jsonData, nil, obj); err != nil {
+		return nil, errors.Wrap(err, "failed to decode object")
+	}
+
+	if !obj.IsList() {
+		return []*unstructured.Unstructured{obj}, nil
+	}
+
+	var objects []*unstructured.Unstructured
+	err = obj.EachListItem(func(item runtime.Object) error {
+		itemObj, ok := item.(*unstructured.Unstructured)
+		if !ok {
+			return errors.Errorf("unexpected list item %T", item)
+		}
+		objects = append(objects, itemObj)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decode items of %s", obj.GetKind())
+	}
+
+	return objects, nil
+}
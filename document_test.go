@@ -0,0 +1,198 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitYAMLDocuments(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []document
+	}{
+		{
+			name:    "single document",
+			content: "kind: ConfigMap\nmetadata:\n  name: a\n",
+			want:    []document{{Data: []byte("kind: ConfigMap\nmetadata:\n  name: a\n"), Line: 1}},
+		},
+		{
+			name:    "separators and empty documents",
+			content: "---\nkind: ConfigMap\n---\n\n---\n\nkind: Secret\n",
+			want: []document{
+				{Data: []byte("kind: ConfigMap\n"), Line: 2},
+				{Data: []byte("kind: Secret\n"), Line: 7},
+			},
+		},
+		{
+			name:    "separator inside block scalar",
+			content: "kind: ConfigMap\ndata:\n  script: |\n    echo a\n    ---\n    echo b\n",
+			want:    []document{{Data: []byte("kind: ConfigMap\ndata:\n  script: |\n    echo a\n    ---\n    echo b\n"), Line: 1}},
+		},
+		{
+			name:    "separator prefix of a longer token",
+			content: "kind: ConfigMap\n----\n",
+			want:    []document{{Data: []byte("kind: ConfigMap\n----\n"), Line: 1}},
+		},
+		{
+			name:    "separator with comment and content",
+			content: "--- # first\nkind: ConfigMap\n--- |\n  text\n",
+			want: []document{
+				{Data: []byte("kind: ConfigMap\n"), Line: 2},
+				{Data: []byte(" |\n  text\n"), Line: 3},
+			},
+		},
+		{
+			name:    "document end marker",
+			content: "kind: ConfigMap\n...\nkind: Secret\n",
+			want: []document{
+				{Data: []byte("kind: ConfigMap\n"), Line: 1},
+				{Data: []byte("kind: Secret\n"), Line: 3},
+			},
+		},
+		{
+			name:    "CRLF line endings",
+			content: "kind: ConfigMap\r\n---\r\nkind: Secret\r\n",
+			want: []document{
+				{Data: []byte("kind: ConfigMap\r\n"), Line: 1},
+				{Data: []byte("kind: Secret\r\n"), Line: 3},
+			},
+		},
+		{
+			name:    "no trailing newline",
+			content: "kind: ConfigMap",
+			want:    []document{{Data: []byte("kind: ConfigMap"), Line: 1}},
+		},
+		{
+			name:    "empty content",
+			content: "",
+			want:    nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := splitYAMLDocuments([]byte(test.content))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSplitJSONDocuments(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []document
+		wantErr bool
+	}{
+		{
+			name:    "single object",
+			content: `{"kind": "ConfigMap"}`,
+			want:    []document{{Data: []byte(`{"kind": "ConfigMap"}`), Line: 1}},
+		},
+		{
+			name:    "stream of objects",
+			content: "{\"kind\": \"ConfigMap\"}\n\n{\n  \"kind\": \"Secret\"\n}\n",
+			want: []document{
+				{Data: []byte(`{"kind": "ConfigMap"}`), Line: 1},
+				{Data: []byte("{\n  \"kind\": \"Secret\"\n}"), Line: 3},
+			},
+		},
+		{
+			name:    "invalid object",
+			content: "{\"kind\": \"ConfigMap\"}\n{\"kind\": ",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := splitJSONDocuments([]byte(test.content))
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want error %v", err, test.wantErr)
+			}
+			if !test.wantErr && !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDecodeDocument(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "object",
+			data: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n",
+			want: []string{"ConfigMap/a"},
+		},
+		{
+			name: "JSON object",
+			data: `{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "b"}}`,
+			want: []string{"Secret/b"},
+		},
+		{
+			name: "List is unwrapped",
+			data: "apiVersion: v1\nkind: List\nitems:\n- apiVersion: v1\n  kind: ConfigMap\n  metadata:\n    name: a\n- apiVersion: apps/v1\n  kind: Deployment\n  metadata:\n    name: b\n",
+			want: []string{"ConfigMap/a", "Deployment/b"},
+		},
+		{
+			name: "empty List",
+			data: "apiVersion: v1\nkind: List\nitems: []\n",
+			want: nil,
+		},
+		{
+			name: "comments only",
+			data: "# nothing here\n",
+			want: nil,
+		},
+		{
+			name:    "invalid YAML",
+			data:    "kind: ConfigMap\n  name: [a\n",
+			wantErr: true,
+		},
+		{
+			name:    "missing kind",
+			data:    "apiVersion: v1\nmetadata:\n  name: a\n",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			objects, err := decodeDocument([]byte(test.data))
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want error %v", err, test.wantErr)
+			}
+
+			var got []string
+			for _, object := range objects {
+				got = append(got, object.GetKind()+"/"+object.GetName())
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestErrorLine(t *testing.T) {
+	_, err := decodeDocument([]byte("kind: ConfigMap\nmetadata:\n  name: [a\n"))
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	// Parser reports line 3 of document, which is line 12 of source for document starting at line 10
+	if got := errorLine(err, 10); got != 12 {
+		t.Errorf("got line %d, want 12", got)
+	}
+}
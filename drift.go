@@ -0,0 +1,84 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/fatih/color"
+	"k8s.io/apimachinery/pkg/api/meta"
+)
+
+// driftExitCode is returned by read-only commands when drift is found
+const driftExitCode = 2
+
+// Drift represents difference between objects of a kind in namespace defined in VCS and existing in cluster
+type Drift struct {
+	Namespace string
+	Kind      string
+	// OnlyVCS are objects defined in VCS, but missing in cluster
+	OnlyVCS []ObjectKey
+	// OnlyCluster are objects existing in cluster, but absent in VCS
+	OnlyCluster []ObjectKey
+	// Both are objects defined in VCS and existing in cluster
+	Both []ObjectKey
+}
+
+// KindDrift compares objects of the given kind in namespace with manifests in both directions
+func (c *Client) KindDrift(namespace string, mapping *meta.RESTMapping, opts CleanerOptions) (*Drift, error) {
+	kind := mapping.GroupVersionKind.Kind
+
+	clusterObjects, err := c.ListObjects(namespace, mapping, opts.ListOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	drift := &Drift{Namespace: namespace, Kind: kind}
+	inCluster := make(map[ObjectKey]bool, len(clusterObjects.Items))
+	for _, object := range clusterObjects.Items {
+		key := KeyOf(&object)
+		inCluster[key] = true
+		if opts.Manifests.Has(key) {
+			drift.Both = append(drift.Both, key)
+		} else {
+			drift.OnlyCluster = append(drift.OnlyCluster, key)
+		}
+	}
+
+	// Manifests out of label selector aren't expected in the listed objects
+	for _, key := range opts.Manifests.Keys(namespace, kind) {
+		if !inCluster[key] && opts.Selects(opts.Manifests.Get(key).Object) {
+			drift.OnlyVCS = append(drift.OnlyVCS, key)
+		}
+	}
+
+	for _, keys := range [][]ObjectKey{drift.OnlyVCS, drift.OnlyCluster, drift.Both} {
+		sort.Slice(keys, func(m, n int) bool {
+			return keys[m].Name < keys[n].Name
+		})
+	}
+
+	return drift, nil
+}
+
+// Drifted returns whether VCS and cluster differ
+func (d *Drift) Drifted() bool {
+	return len(d.OnlyVCS) > 0 || len(d.OnlyCluster) > 0
+}
+
+// Print prints drift with locations of manifests missing in cluster
+func (d *Drift) Print(index *ManifestIndex) {
+	color.Cyan("%s: %d in both, %d only in VCS, %d only in cluster", d.Kind, len(d.Both), len(d.OnlyVCS), len(d.OnlyCluster))
+
+	for _, key := range d.OnlyVCS {
+		location := ""
+		if manifest := index.Get(key); manifest != nil {
+			location = ", defined at " + manifest.Location.String()
+		}
+		color.Yellow("  + %s %s is missing in cluster%s", d.Kind, key.Name, location)
+	}
+	for _, key := range d.OnlyCluster {
+		color.Red("  - %s %s is absent in VCS", d.Kind, key.Name)
+	}
+	for _, key := range d.Both {
+		color.Green("  = %s %s", d.Kind, key.Name)
+	}
+}
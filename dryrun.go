@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DryRunMode decides whether and how deletions are simulated
+type DryRunMode string
+
+const (
+	// dryRunClient only prints objects which would be deleted
+	dryRunClient DryRunMode = "client"
+	// dryRunServer sends deletions to API server, which validates them without persisting
+	dryRunServer DryRunMode = "server"
+	// dryRunNone deletes objects
+	dryRunNone DryRunMode = "none"
+)
+
+// ParseDryRun returns dry-run mode of the given value, true and false stand for client and none
+func ParseDryRun(value string) (DryRunMode, error) {
+	switch strings.ToLower(value) {
+	case "true", string(dryRunClient):
+		return dryRunClient, nil
+	case "false", string(dryRunNone):
+		return dryRunNone, nil
+	case string(dryRunServer):
+		return dryRunServer, nil
+	}
+
+	return "", errors.Errorf("unknown dry-run mode %s, supported modes are %s, %s, %s", value, dryRunClient, dryRunServer, dryRunNone)
+}
+
+// UnmarshalJSON accepts dry-run mode as boolean or string, e.g. false or server
+func (m *DryRunMode) UnmarshalJSON(data []byte) error {
+	var enabled bool
+	if err := json.Unmarshal(data, &enabled); err == nil {
+		*m = DryRunMode(strconv.FormatBool(enabled))
+		return nil
+	}
+
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return errors.Errorf("dry-run mode must be boolean or one of %s, %s, %s", dryRunClient, dryRunServer, dryRunNone)
+	}
+	*m = DryRunMode(value)
+
+	return nil
+}
+
+// ReportServerDryRun prints answer of API server to dry-run deletion of the given object
+func ReportServerDryRun(kind string, object metav1.Object, err error) {
+	switch {
+	case IsChanged(err):
+		color.Yellow("%s %s changed since listing, skipped", kind, object.GetName())
+	case err != nil:
+		color.Red("  API server refused deletion of %s %s: %s", kind, object.GetName(), errors.Cause(err))
+	case len(object.GetFinalizers()) > 0:
+		color.Yellow("  API server accepted deletion of %s %s, it will wait for finalizers %s", kind, object.GetName(), strings.Join(object.GetFinalizers(), ", "))
+	default:
+		color.Green("  API server accepted deletion of %s %s", kind, object.GetName())
+	}
+}
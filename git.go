@@ -0,0 +1,210 @@
+package main
+
+import (
+	"path"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultGitRevision = "HEAD"
+)
+
+// GitSource describes git repository manifests are read from
+type GitSource struct {
+	// Repository is path to local bare repository or working copy
+	Repository string
+	// Revision is branch, tag, commit or revision expression like HEAD~1
+	Revision string
+}
+
+// ResolveCommit returns commit the revision of the given source points to
+func ResolveCommit(source GitSource) (*object.Commit, error) {
+	repo, err := git.PlainOpenWithOptions(source.Repository, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open git repository %s", source.Repository)
+	}
+
+	hash, err := repo.ResolveRevision(plumbing.Revision(source.Revision))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to resolve revision %s", source.Revision)
+	}
+
+	commit, err := repo.CommitObject(*hash)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read commit %s", hash)
+	}
+
+	return commit, nil
+}
+
+// CollectObjectsFromGit reads manifests from git repository at the given revision without touching working tree
+// and puts present objects to index. Paths of dirs are directories inside repository, filtered the same way as
+// in CollectObjectsFromDir. It returns SHA of resolved commit
+func CollectObjectsFromGit(source GitSource, dirs ManifestDirs, index *ManifestIndex) (string, error) {
+	commit, err := ResolveCommit(source)
+	if err != nil {
+		return "", err
+	}
+
+	if err := checkDirectories(commit, dirs.Paths); err != nil {
+		return "", err
+	}
+	if err := collectObjectsFromCommit(source.Repository, commit, dirs, index); err != nil {
+		return "", err
+	}
+
+	return commit.Hash.String(), nil
+}
+
+// CollectRemovedObjectsFromGit reads manifests at both ends of revision range (A..B, or A...B for the range
+// starting at merge-base of A and B). Objects present at the head of range are put to index, objects which were
+// deleted or renamed in range are put to removed. It returns SHAs of base and head commits
+func CollectRemovedObjectsFromGit(repository, gitRange string, dirs ManifestDirs, index, removed *ManifestIndex) (string, string, error) {
+	baseRevision, headRevision, mergeBase, err := ParseGitRange(gitRange)
+	if err != nil {
+		return "", "", err
+	}
+
+	base, err := ResolveCommit(GitSource{Repository: repository, Revision: baseRevision})
+	if err != nil {
+		return "", "", err
+	}
+
+	head, err := ResolveCommit(GitSource{Repository: repository, Revision: headRevision})
+	if err != nil {
+		return "", "", err
+	}
+
+	if mergeBase {
+		bases, err := base.MergeBase(head)
+		if err != nil {
+			return "", "", errors.Wrapf(err, "failed to find merge-base of %s and %s", baseRevision, headRevision)
+		}
+		if len(bases) == 0 {
+			return "", "", errors.Errorf("%s and %s have no merge-base", baseRevision, headRevision)
+		}
+		base = bases[0]
+	}
+
+	// Directories may be added in range, so only head of range has to contain them
+	if err := checkDirectories(head, dirs.Paths); err != nil {
+		return "", "", err
+	}
+
+	baseIndex := NewManifestIndex()
+	if err := collectObjectsFromCommit(repository, base, dirs, baseIndex); err != nil {
+		return "", "", err
+	}
+
+	headIndex := NewManifestIndex()
+	if err := collectObjectsFromCommit(repository, head, dirs, headIndex); err != nil {
+		return "", "", err
+	}
+
+	index.Merge(headIndex)
+	removed.Merge(baseIndex.Difference(headIndex))
+
+	return base.Hash.String(), head.Hash.String(), nil
+}
+
+// ParseGitRange splits revision range in A..B or A...B form, omitted end means HEAD
+func ParseGitRange(gitRange string) (base, head string, mergeBase bool, err error) {
+	separator := ".."
+	if strings.Contains(gitRange, "...") {
+		separator = "..."
+		mergeBase = true
+	}
+
+	revisions := strings.Split(gitRange, separator)
+	if len(revisions) != 2 {
+		return "", "", false, errors.Errorf("invalid revision range %s, expected A..B or A...B", gitRange)
+	}
+
+	base, head = revisions[0], revisions[1]
+	if base == "" {
+		base = defaultGitRevision
+	}
+	if head == "" {
+		head = defaultGitRevision
+	}
+
+	return base, head, mergeBase, nil
+}
+
+// checkDirectories returns error if some of directories doesn't exist in tree of the given commit, so a typo
+// or a path outside repository never makes all objects look absent in VCS
+func checkDirectories(commit *object.Commit, directories []string) error {
+	tree, err := commit.Tree()
+	if err != nil {
+		return errors.Wrapf(err, "failed to read tree of commit %s", commit.Hash)
+	}
+
+	for _, directory := range directories {
+		dir := repositoryPath(directory)
+		if dir == "" {
+			continue
+		}
+		if _, err := tree.Tree(dir); err != nil {
+			return errors.Wrapf(err, "failed to find directory %s at %s, directories are relative to repository root", directory, commit.Hash)
+		}
+	}
+
+	return nil
+}
+
+// collectObjectsFromCommit reads manifests from tree of the given commit and puts present objects to index
+func collectObjectsFromCommit(repository string, commit *object.Commit, dirs ManifestDirs, index *ManifestIndex) error {
+	tree, err := commit.Tree()
+	if err != nil {
+		return errors.Wrapf(err, "failed to read tree of commit %s", commit.Hash)
+	}
+
+	sha := commit.Hash.String()
+	err = tree.Files().ForEach(func(file *object.File) error {
+		if !isManifestFile(file.Name) || !inDirectories(file.Name, dirs.Paths) {
+			return nil
+		}
+
+		content, err := file.Contents()
+		if err != nil {
+			return errors.Wrapf(err, "failed to read %s", file.Name)
+		}
+
+		collectObjects([]byte(content), dirs.NamespaceFor(file.Name), repository+"@"+sha+":"+file.Name, index)
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to read manifests at %s", sha)
+	}
+
+	index.SetRevision(repository, sha)
+
+	return nil
+}
+
+// inDirectories returns whether the given file of repository is located in one of directories (recursively).
+// Empty list of directories means the whole repository
+func inDirectories(file string, directories []string) bool {
+	if len(directories) == 0 {
+		return true
+	}
+
+	for _, directory := range directories {
+		directory = repositoryPath(directory)
+		if directory == "" || strings.HasPrefix(file, directory+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// repositoryPath returns the given directory as clean path relative to repository root, empty for the root
+func repositoryPath(directory string) string {
+	return strings.TrimPrefix(path.Clean("/"+directory), "/")
+}
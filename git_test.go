@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestParseGitRange(t *testing.T) {
+	tests := []struct {
+		gitRange      string
+		wantBase      string
+		wantHead      string
+		wantMergeBase bool
+		wantErr       bool
+	}{
+		{gitRange: "HEAD~1..HEAD", wantBase: "HEAD~1", wantHead: "HEAD"},
+		{gitRange: "main...feature", wantBase: "main", wantHead: "feature", wantMergeBase: true},
+		{gitRange: "v1.0.0..", wantBase: "v1.0.0", wantHead: "HEAD"},
+		{gitRange: "..feature", wantBase: "HEAD", wantHead: "feature"},
+		{gitRange: "main...", wantBase: "main", wantHead: "HEAD", wantMergeBase: true},
+		{gitRange: "HEAD", wantErr: true},
+		{gitRange: "a..b..c", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.gitRange, func(t *testing.T) {
+			base, head, mergeBase, err := ParseGitRange(test.gitRange)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want error %v", err, test.wantErr)
+			}
+			if test.wantErr {
+				return
+			}
+			if base != test.wantBase || head != test.wantHead || mergeBase != test.wantMergeBase {
+				t.Errorf("got %s, %s, %v, want %s, %s, %v", base, head, mergeBase, test.wantBase, test.wantHead, test.wantMergeBase)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/fatih/color"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// OwnershipDetector recognizes objects managed by a GitOps tool, such objects are never pruned
+type OwnershipDetector struct {
+	// Name of the tool
+	Name string
+	// Labels mark managed objects, any value matches if value is empty
+	Labels map[string]string
+	// Annotations mark managed objects, any value matches
+	Annotations []string
+}
+
+// ownershipDetectors are built-in detectors of GitOps tools
+var ownershipDetectors = []OwnershipDetector{
+	{
+		Name:        "helm",
+		Labels:      map[string]string{managedByLabel: "Helm"},
+		Annotations: []string{"meta.helm.sh/release-name"},
+	},
+	{
+		Name:        "argocd",
+		Labels:      map[string]string{"argocd.argoproj.io/instance": ""},
+		Annotations: []string{"argocd.argoproj.io/tracking-id"},
+	},
+	{
+		Name:   "flux",
+		Labels: map[string]string{"kustomize.toolkit.fluxcd.io/name": "", "helm.toolkit.fluxcd.io/name": ""},
+	},
+}
+
+// DetectorNames returns names of built-in ownership detectors
+func DetectorNames() []string {
+	var names []string
+	for _, detector := range ownershipDetectors {
+		names = append(names, detector.Name)
+	}
+
+	return names
+}
+
+// NewOwnershipDetectors returns built-in detectors with the given names
+func NewOwnershipDetectors(names []string) ([]OwnershipDetector, error) {
+	var detectors []OwnershipDetector
+	for _, name := range names {
+		found := false
+		for _, detector := range ownershipDetectors {
+			if detector.Name == name {
+				detectors = append(detectors, detector)
+				found = true
+			}
+		}
+		if !found {
+			return nil, errors.Errorf("unknown ownership detector %s, supported detectors are %v", name, DetectorNames())
+		}
+	}
+
+	return detectors, nil
+}
+
+// Matches returns whether the given object is managed by the tool
+func (d OwnershipDetector) Matches(object metav1.Object) bool {
+	objectLabels := object.GetLabels()
+	for key, value := range d.Labels {
+		if actual, ok := objectLabels[key]; ok && (value == "" || actual == value) {
+			return true
+		}
+	}
+
+	objectAnnotations := object.GetAnnotations()
+	for _, key := range d.Annotations {
+		if _, ok := objectAnnotations[key]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// DetectOwner returns name of the first detector recognizing the given object, empty string if there is no one
+func DetectOwner(detectors []OwnershipDetector, object metav1.Object) string {
+	for _, detector := range detectors {
+		if detector.Matches(object) {
+			return detector.Name
+		}
+	}
+
+	return ""
+}
+
+// Summary counts results of a run
+type Summary struct {
+	// Excluded is the number of objects excluded by each ownership detector
+	Excluded map[string]int
+}
+
+// NewSummary returns empty summary of the given detectors
+func NewSummary(detectors []OwnershipDetector) *Summary {
+	summary := &Summary{Excluded: make(map[string]int)}
+	for _, detector := range detectors {
+		summary.Excluded[detector.Name] = 0
+	}
+
+	return summary
+}
+
+// Exclude counts object excluded by the given detector
+func (s *Summary) Exclude(detector string) {
+	if s != nil {
+		s.Excluded[detector]++
+	}
+}
+
+// Print prints summary of the run
+func (s *Summary) Print() {
+	var detectors []string
+	for detector := range s.Excluded {
+		detectors = append(detectors, detector)
+	}
+	sort.Strings(detectors)
+
+	color.Cyan("     === SUMMARY\n")
+	for _, detector := range detectors {
+		color.Cyan("Excluded by %s detector: %d", detector, s.Excluded[detector])
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	orphanedSinceAnnotation = "k8s-cleaner.io/orphaned-since"
+	orphanedRunsAnnotation  = "k8s-cleaner.io/orphaned-runs"
+)
+
+// GracePolicy delays pruning of new objects and objects which have just disappeared from VCS
+type GracePolicy struct {
+	// MinAge is the minimum age of objects by creationTimestamp
+	MinAge time.Duration
+	// Period is the minimum time object has to stay absent in VCS
+	Period time.Duration
+	// Runs is the minimum number of consecutive runs object has to stay absent in VCS
+	Runs int64
+}
+
+// Orphan represents the state of object absent in VCS recorded in its annotations
+type Orphan struct {
+	Since time.Time
+	Runs  int64
+}
+
+// Enabled returns whether orphaned objects are tracked between runs
+func (p GracePolicy) Enabled() bool {
+	return p.Period > 0 || p.Runs > 1
+}
+
+// Young returns age of the given object and whether it is younger than minimum age
+func (p GracePolicy) Young(object metav1.Object, now time.Time) (time.Duration, bool) {
+	age := now.Sub(object.GetCreationTimestamp().Time)
+
+	return age, age < p.MinAge
+}
+
+// Ready returns whether orphaned object has stayed absent in VCS long enough to be pruned
+func (p GracePolicy) Ready(orphan Orphan, now time.Time) bool {
+	return now.Sub(orphan.Since) >= p.Period && orphan.Runs >= p.Runs
+}
+
+// NextOrphan returns state of the given object absent in VCS in the current run. Tracking starts over if
+// annotations are absent or invalid
+func NextOrphan(object metav1.Object, now time.Time) Orphan {
+	annotations := object.GetAnnotations()
+
+	since, err := time.Parse(time.RFC3339, annotations[orphanedSinceAnnotation])
+	if err != nil {
+		return Orphan{Since: now, Runs: 1}
+	}
+	runs, err := strconv.ParseInt(annotations[orphanedRunsAnnotation], 10, 64)
+	if err != nil || runs < 1 {
+		return Orphan{Since: now, Runs: 1}
+	}
+
+	return Orphan{Since: since, Runs: runs + 1}
+}
+
+// IsOrphaned returns whether the given object is marked as absent in VCS
+func IsOrphaned(object metav1.Object) bool {
+	_, ok := object.GetAnnotations()[orphanedSinceAnnotation]
+
+	return ok
+}
+
+// MarkOrphan records state of object absent in VCS in its annotations
+func (c *Client) MarkOrphan(mapping *meta.RESTMapping, object unstructured.Unstructured, orphan Orphan) error {
+	return c.PatchObject(mapping, object, map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{
+				orphanedSinceAnnotation: orphan.Since.UTC().Format(time.RFC3339),
+				orphanedRunsAnnotation:  strconv.FormatInt(orphan.Runs, 10),
+			},
+		},
+	})
+}
+
+// UnmarkOrphan removes state of absent in VCS object from its annotations
+func (c *Client) UnmarkOrphan(mapping *meta.RESTMapping, object unstructured.Unstructured) error {
+	return c.PatchObject(mapping, object, map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{
+				orphanedSinceAnnotation: nil,
+				orphanedRunsAnnotation:  nil,
+			},
+		},
+	})
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/engine"
+)
+
+const (
+	helmNotesFile = "NOTES.txt"
+)
+
+// HelmChart describes local chart rendered as a source of manifests
+type HelmChart struct {
+	// Path to chart directory or packaged chart
+	Path string `json:"path"`
+	// ReleaseName is used for rendering, chart name by default
+	ReleaseName string `json:"releaseName,omitempty"`
+	// Namespace of release, it is also used for objects without metadata.namespace
+	Namespace string `json:"namespace,omitempty"`
+	// ValuesFiles are merged in the given order, the last file wins
+	ValuesFiles []string `json:"valuesFiles,omitempty"`
+}
+
+// CollectObjectsFromChart renders the given chart through Helm template engine and puts rendered objects to index.
+// It returns the rendered release
+func CollectObjectsFromChart(helmChart HelmChart, index *ManifestIndex) (HelmRelease, error) {
+	chrt, err := loader.Load(helmChart.Path)
+	if err != nil {
+		return HelmRelease{}, errors.Wrapf(err, "failed to load chart %s", helmChart.Path)
+	}
+
+	values := map[string]interface{}{}
+	for _, file := range helmChart.ValuesFiles {
+		fileValues, err := chartutil.ReadValuesFile(file)
+		if err != nil {
+			return HelmRelease{}, errors.Wrapf(err, "failed to read values file %s", file)
+		}
+		values = chartutil.CoalesceTables(fileValues.AsMap(), values)
+	}
+
+	releaseName := helmChart.ReleaseName
+	if releaseName == "" {
+		releaseName = chrt.Name()
+	}
+
+	if err := chartutil.ProcessDependencies(chrt, values); err != nil {
+		return HelmRelease{}, errors.Wrapf(err, "failed to process dependencies of chart %s", helmChart.Path)
+	}
+
+	renderValues, err := chartutil.ToRenderValues(chrt, values, chartutil.ReleaseOptions{
+		Name:      releaseName,
+		Namespace: helmChart.Namespace,
+		Revision:  1,
+		IsInstall: true,
+	}, chartutil.DefaultCapabilities)
+	if err != nil {
+		return HelmRelease{}, errors.Wrapf(err, "failed to compose values of chart %s", helmChart.Path)
+	}
+
+	rendered, err := engine.Render(chrt, renderValues)
+	if err != nil {
+		return HelmRelease{}, errors.Wrapf(err, "failed to render chart %s", helmChart.Path)
+	}
+
+	var files []string
+	for file := range rendered {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		if path.Base(file) == helmNotesFile || strings.TrimSpace(rendered[file]) == "" {
+			continue
+		}
+		collectObjects([]byte(rendered[file]), helmChart.Namespace, file, index)
+	}
+
+	return HelmRelease{Namespace: helmChart.Namespace, Name: releaseName}, nil
+}
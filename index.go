@@ -0,0 +1,244 @@
+package main
+
+import (
+	"sort"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ObjectKey identifies Kubernetes object by namespace, kind and name. API group is not a part of the key,
+// because the same objects are often served by several groups (e.g. extensions and apps Deployments)
+type ObjectKey struct {
+	Namespace string
+	Kind      string
+	Name      string
+}
+
+// String returns the key in namespace/kind/name form
+func (k ObjectKey) String() string {
+	return k.Namespace + "/" + k.Kind + "/" + k.Name
+}
+
+// KeyOf returns key of the given object
+func KeyOf(object *unstructured.Unstructured) ObjectKey {
+	return ObjectKey{
+		Namespace: object.GetNamespace(),
+		Kind:      object.GetKind(),
+		Name:      object.GetName(),
+	}
+}
+
+// Manifest represents object definition read from manifests
+type Manifest struct {
+	Object   *unstructured.Unstructured
+	Location Location
+}
+
+// ManifestIndex holds objects defined in manifests. It is built once at startup and is safe for concurrent use
+type ManifestIndex struct {
+	mu        sync.RWMutex
+	objects   map[ObjectKey]*Manifest
+	revisions map[string]string
+	errors    []ParseError
+}
+
+// ParseError represents document which couldn't be read or decoded
+type ParseError struct {
+	Location Location
+	Err      error
+}
+
+// Error returns error message prefixed with location
+func (e ParseError) Error() string {
+	return e.Location.String() + ": " + e.Err.Error()
+}
+
+// NewManifestIndex creates empty ManifestIndex
+func NewManifestIndex() *ManifestIndex {
+	return &ManifestIndex{
+		objects:   make(map[ObjectKey]*Manifest),
+		revisions: make(map[string]string),
+	}
+}
+
+// Add puts the object to index. Object without namespace is put to the given namespace
+func (i *ManifestIndex) Add(object *unstructured.Unstructured, namespace string, location Location) {
+	if object.GetNamespace() == "" {
+		object.SetNamespace(namespace)
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.objects[KeyOf(object)] = &Manifest{
+		Object:   object,
+		Location: location,
+	}
+}
+
+// AddError records document which couldn't be read or decoded
+func (i *ManifestIndex) AddError(location Location, err error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.errors = append(i.errors, ParseError{
+		Location: location,
+		Err:      err,
+	})
+}
+
+// Errors returns documents which couldn't be read or decoded ordered by location
+func (i *ManifestIndex) Errors() []ParseError {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	errs := make([]ParseError, len(i.errors))
+	copy(errs, i.errors)
+	sort.Slice(errs, func(m, n int) bool {
+		return errs[m].Location.Less(errs[n].Location)
+	})
+
+	return errs
+}
+
+// Manifests returns all indexed objects ordered by location
+func (i *ManifestIndex) Manifests() []*Manifest {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	manifests := make([]*Manifest, 0, len(i.objects))
+	for _, manifest := range i.objects {
+		manifests = append(manifests, manifest)
+	}
+	sort.Slice(manifests, func(m, n int) bool {
+		return manifests[m].Location.Less(manifests[n].Location)
+	})
+
+	return manifests
+}
+
+// Has returns whether object with the given key is defined in manifests
+func (i *ManifestIndex) Has(key ObjectKey) bool {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	_, ok := i.objects[key]
+	return ok
+}
+
+// Get returns manifest of object with the given key or nil if it isn't defined in manifests
+func (i *ManifestIndex) Get(key ObjectKey) *Manifest {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return i.objects[key]
+}
+
+// Keys returns keys of objects of the given kind targeting the given namespace
+func (i *ManifestIndex) Keys(namespace, kind string) []ObjectKey {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	var keys []ObjectKey
+	for key := range i.objects {
+		if key.Namespace == namespace && key.Kind == kind {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// Len returns the number of indexed objects
+func (i *ManifestIndex) Len() int {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return len(i.objects)
+}
+
+// Merge puts all objects and revisions of other index to this index
+func (i *ManifestIndex) Merge(other *ManifestIndex) {
+	other.mu.RLock()
+	defer other.mu.RUnlock()
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for key, manifest := range other.objects {
+		i.objects[key] = manifest
+	}
+	for source, revision := range other.revisions {
+		i.revisions[source] = revision
+	}
+	i.errors = append(i.errors, other.errors...)
+}
+
+// MergeMissing puts objects of other index which are absent in this index, revisions and errors of other index
+// to this index
+func (i *ManifestIndex) MergeMissing(other *ManifestIndex) {
+	other.mu.RLock()
+	defer other.mu.RUnlock()
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for key, manifest := range other.objects {
+		if _, ok := i.objects[key]; !ok {
+			i.objects[key] = manifest
+		}
+	}
+	for source, revision := range other.revisions {
+		i.revisions[source] = revision
+	}
+	i.errors = append(i.errors, other.errors...)
+}
+
+// Difference returns a new index, containing objects of this index which are absent in other index
+func (i *ManifestIndex) Difference(other *ManifestIndex) *ManifestIndex {
+	difference := NewManifestIndex()
+
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	for key, manifest := range i.objects {
+		if !other.Has(key) {
+			difference.objects[key] = manifest
+		}
+	}
+
+	return difference
+}
+
+// SetRevision records VCS revision the given source was read at
+func (i *ManifestIndex) SetRevision(source, revision string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.revisions[source] = revision
+}
+
+// Revisions returns VCS revisions of sources read at a specific revision
+func (i *ManifestIndex) Revisions() map[string]string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	revisions := make(map[string]string, len(i.revisions))
+	for source, revision := range i.revisions {
+		revisions[source] = revision
+	}
+
+	return revisions
+}
+
+// Except returns a new slice, containing all objects from the left slice (k8scluster) which are absent in index (VCS)
+func Except(left []unstructured.Unstructured, index *ManifestIndex) []unstructured.Unstructured {
+	var objects []unstructured.Unstructured
+
+	for _, object := range left {
+		if !index.Has(KeyOf(&object)) {
+			objects = append(objects, object)
+		}
+	}
+
+	return objects
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/release"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	helmReleaseSecretType = "helm.sh/release.v1"
+	helmReleaseSelector   = "owner=helm"
+	helmReleaseKey        = "release"
+	helmNameLabel         = "name"
+	helmStatusLabel       = "status"
+	helmVersionLabel      = "version"
+)
+
+// gzipMagic starts gzip compressed releases
+var gzipMagic = []byte{0x1f, 0x8b, 0x08}
+
+// HelmRelease identifies Helm release by namespace and name
+type HelmRelease struct {
+	Namespace string
+	Name      string
+}
+
+// String returns the release in namespace/name form
+func (r HelmRelease) String() string {
+	return r.Namespace + "/" + r.Name
+}
+
+// ListHelmReleaseSecrets returns the list of Secrets storing Helm releases
+func (c *Client) ListHelmReleaseSecrets(namespace string, listOptions metav1.ListOptions) ([]corev1.Secret, error) {
+	listOptions.LabelSelector = JoinSelectors(helmReleaseSelector, listOptions.LabelSelector)
+	secrets, err := c.clientset.CoreV1().Secrets(namespace).List(listOptions)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to retrieve Helm release Secrets")
+	}
+
+	var releases []corev1.Secret
+	for _, secret := range secrets.Items {
+		if secret.Type == helmReleaseSecretType {
+			releases = append(releases, secret)
+		}
+	}
+
+	return releases, nil
+}
+
+// DeleteHelmReleaseSecret deletes the given Secret storing Helm release unless it changed since listing
+func (c *Client) DeleteHelmReleaseSecret(secret corev1.Secret, dryRun bool) error {
+	if err := c.clientset.CoreV1().Secrets(secret.Namespace).Delete(secret.Name, DeleteOptions(&secret, dryRun)); err != nil {
+		return errors.Wrap(err, "failed to delete Helm release Secret")
+	}
+
+	return nil
+}
+
+// HelmReleaseVersion returns revision of release stored in the given Secret
+func HelmReleaseVersion(secret corev1.Secret) int {
+	version, err := strconv.Atoi(secret.Labels[helmVersionLabel])
+	if err != nil {
+		return 0
+	}
+
+	return version
+}
+
+// DecodeHelmRelease decodes release stored in the given Secret as base64 encoded, optionally gzipped JSON
+func DecodeHelmRelease(secret corev1.Secret) (*release.Release, error) {
+	data, err := base64.StdEncoding.DecodeString(string(secret.Data[helmReleaseKey]))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decode Helm release %s", secret.Name)
+	}
+
+	if bytes.HasPrefix(data, gzipMagic) {
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decompress Helm release %s", secret.Name)
+		}
+		data, err = ioutil.ReadAll(reader)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decompress Helm release %s", secret.Name)
+		}
+	}
+
+	rls := &release.Release{}
+	if err := json.Unmarshal(data, rls); err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal Helm release %s", secret.Name)
+	}
+
+	return rls, nil
+}
+
+// CollectObjectsFromHelmReleases puts objects of the latest deployed revision of every Helm release in the given
+// namespace to index and returns found releases
+func (c *Client) CollectObjectsFromHelmReleases(namespace string, index *ManifestIndex) ([]HelmRelease, error) {
+	secrets, err := c.ListHelmReleaseSecrets(namespace, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	deployed := make(map[string]corev1.Secret)
+	for _, secret := range secrets {
+		if secret.Labels[helmStatusLabel] != release.StatusDeployed.String() {
+			continue
+		}
+		name := secret.Labels[helmNameLabel]
+		if latest, ok := deployed[name]; !ok || HelmReleaseVersion(secret) > HelmReleaseVersion(latest) {
+			deployed[name] = secret
+		}
+	}
+
+	var releases []HelmRelease
+	for name, secret := range deployed {
+		rls, err := DecodeHelmRelease(secret)
+		if err != nil {
+			return nil, err
+		}
+
+		source := fmt.Sprintf("helm-release:%s/%s@v%d", namespace, name, rls.Version)
+		collectObjects([]byte(rls.Manifest), namespace, source, index)
+		releases = append(releases, HelmRelease{Namespace: namespace, Name: name})
+	}
+	sort.Slice(releases, func(m, n int) bool {
+		return releases[m].Name < releases[n].Name
+	})
+
+	return releases, nil
+}
+
+// ReportHelmReleases prints Helm releases deployed in cluster which aren't rendered from charts of VCS
+func ReportHelmReleases(releases []HelmRelease, charts []HelmRelease) {
+	known := make(map[HelmRelease]bool, len(charts))
+	for _, chart := range charts {
+		known[chart] = true
+	}
+
+	for _, rls := range releases {
+		if !known[rls] {
+			color.Yellow("Helm release %s exists in cluster, but not in VCS", rls)
+		}
+	}
+}
+
+// HelmHistoryCleaner deletes Secrets of Helm release revisions except the latest historyMax revisions and
+// the deployed one of every release
+func (c *Client) HelmHistoryCleaner(namespace string, historyMax int64, opts CleanerOptions) error {
+	secrets, err := c.ListHelmReleaseSecrets(namespace, opts.ListOptions())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	history := map[string][]corev1.Secret{}
+	for _, secret := range secrets {
+		name := secret.Labels[helmNameLabel]
+		history[name] = append(history[name], secret)
+	}
+
+	var names []string
+	for name := range history {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	now := time.Now()
+	for _, name := range names {
+		revisions := history[name]
+		sort.Slice(revisions, func(m, n int) bool {
+			return HelmReleaseVersion(revisions[m]) > HelmReleaseVersion(revisions[n])
+		})
+
+		for i, secret := range revisions {
+			if int64(i) < historyMax || secret.Labels[helmStatusLabel] == release.StatusDeployed.String() {
+				continue
+			}
+
+			if age, young := opts.Grace.Young(&secret, now); young {
+				color.Yellow("Skipping Helm release %s revision %d: created %s ago, younger than min age %s", name, HelmReleaseVersion(secret), age.Round(time.Second), opts.Grace.MinAge)
+				continue
+			}
+
+			if reason := opts.Protection.Reason("Secret", &secret); reason != "" {
+				color.Red("You can't delete Secret %s: %s", secret.Name, reason)
+				continue
+			}
+
+			if opts.DryRun {
+				color.Yellow("******************************************************************************")
+				color.Yellow("  Deleting Helm release %s revision %d [dry-run]\n", name, HelmReleaseVersion(secret))
+				color.Yellow("******************************************************************************")
+				opts.Plan.Add(&secret, "v1", "Secret")
+				if opts.ServerDryRun {
+					ReportServerDryRun("Secret", &secret, c.DeleteHelmReleaseSecret(secret, true))
+				}
+			} else {
+				color.Red("******************************************************************************")
+				color.Red("  Deleting Helm release %s revision %d\n", name, HelmReleaseVersion(secret))
+				color.Red("******************************************************************************")
+				if err := opts.Backup.WriteTyped(&secret, "v1", "Secret"); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					os.Exit(1)
+				}
+				if err := c.DeleteHelmReleaseSecret(secret, false); err != nil {
+					if err := opts.Backup.DiscardTyped(&secret, "v1", "Secret"); err != nil {
+						fmt.Fprintln(os.Stderr, err)
+						os.Exit(1)
+					}
+					if IsChanged(err) {
+						color.Yellow("Helm release %s revision %d changed since listing, skipped", name, HelmReleaseVersion(secret))
+						continue
+					}
+					fmt.Fprintln(os.Stderr, err)
+					os.Exit(1)
+				}
+			}
+		}
+	}
+
+	return nil
+}
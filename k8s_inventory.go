@@ -0,0 +1,124 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	inventoryKeySeparator = "_"
+	managedByLabel        = "app.kubernetes.io/managed-by"
+	managedByValue        = "k8s-cleaner"
+)
+
+// Inventory represents the set of objects of namespace applied by pipeline. It is stored in ConfigMap with
+// kind_name keys, only objects recorded in inventory are pruned
+type Inventory struct {
+	configMap *corev1.ConfigMap
+	objects   map[ObjectKey]bool
+	// stored is false until ConfigMap of inventory is created
+	stored bool
+}
+
+// GetInventory returns inventory stored in the given ConfigMap, empty inventory if ConfigMap doesn't exist yet
+func (c *Client) GetInventory(namespace, name string) (*Inventory, error) {
+	inventory := &Inventory{
+		objects: make(map[ObjectKey]bool),
+	}
+
+	configMap, err := c.clientset.CoreV1().ConfigMaps(namespace).Get(name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		inventory.configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				Labels:    map[string]string{managedByLabel: managedByValue},
+			},
+		}
+		return inventory, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to retrieve inventory %s", name)
+	}
+
+	inventory.configMap = configMap
+	inventory.stored = true
+	for key := range configMap.Data {
+		parts := strings.SplitN(key, inventoryKeySeparator, 2)
+		if len(parts) != 2 {
+			continue
+		}
+		inventory.objects[ObjectKey{Namespace: namespace, Kind: parts[0], Name: parts[1]}] = true
+	}
+
+	return inventory, nil
+}
+
+// SaveInventory creates or updates ConfigMap of the given inventory
+func (c *Client) SaveInventory(inventory *Inventory) error {
+	data := make(map[string]string, len(inventory.objects))
+	for key := range inventory.objects {
+		data[key.Kind+inventoryKeySeparator+key.Name] = ""
+	}
+	inventory.configMap.Data = data
+
+	configMaps := c.clientset.CoreV1().ConfigMaps(inventory.configMap.Namespace)
+
+	var (
+		configMap *corev1.ConfigMap
+		err       error
+	)
+	if inventory.stored {
+		configMap, err = configMaps.Update(inventory.configMap)
+	} else {
+		configMap, err = configMaps.Create(inventory.configMap)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to save inventory %s", inventory.configMap.Name)
+	}
+
+	inventory.configMap = configMap
+	inventory.stored = true
+
+	return nil
+}
+
+// Has returns whether object with the given key is recorded in inventory
+func (i *Inventory) Has(key ObjectKey) bool {
+	return i.objects[key]
+}
+
+// Keys returns recorded objects of the given kind
+func (i *Inventory) Keys(kind string) []ObjectKey {
+	var keys []ObjectKey
+	for key := range i.objects {
+		if key.Kind == kind {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// Update replaces objects of the given kind in inventory with objects defined in manifests and previously
+// recorded objects which are still kept in cluster
+func (i *Inventory) Update(kind string, manifests []ObjectKey, kept []ObjectKey) {
+	keep := make(map[ObjectKey]bool, len(kept))
+	for _, key := range kept {
+		keep[key] = true
+	}
+
+	for key := range i.objects {
+		if key.Kind == kind && !keep[key] {
+			delete(i.objects, key)
+		}
+	}
+
+	for _, key := range manifests {
+		i.objects[key] = true
+	}
+}
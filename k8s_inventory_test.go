@@ -0,0 +1,74 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestInventoryUpdate(t *testing.T) {
+	key := func(kind, name string) ObjectKey {
+		return ObjectKey{Namespace: "default", Kind: kind, Name: name}
+	}
+
+	tests := []struct {
+		name      string
+		recorded  []ObjectKey
+		manifests []ObjectKey
+		kept      []ObjectKey
+		want      []ObjectKey
+	}{
+		{
+			name:      "empty inventory records manifests",
+			manifests: []ObjectKey{key("Deployment", "a")},
+			want:      []ObjectKey{key("Deployment", "a")},
+		},
+		{
+			name:     "deleted objects are removed",
+			recorded: []ObjectKey{key("Deployment", "a"), key("Deployment", "b")},
+			kept:     []ObjectKey{key("Deployment", "a")},
+			want:     []ObjectKey{key("Deployment", "a")},
+		},
+		{
+			name:      "kept objects absent in VCS stay recorded",
+			recorded:  []ObjectKey{key("Deployment", "a"), key("Deployment", "b")},
+			manifests: []ObjectKey{key("Deployment", "a")},
+			kept:      []ObjectKey{key("Deployment", "a"), key("Deployment", "b")},
+			want:      []ObjectKey{key("Deployment", "a"), key("Deployment", "b")},
+		},
+		{
+			name:     "unrecorded kept objects aren't added",
+			recorded: []ObjectKey{key("Deployment", "a")},
+			kept:     []ObjectKey{key("Deployment", "a"), key("Deployment", "manual")},
+			want:     []ObjectKey{key("Deployment", "a")},
+		},
+		{
+			name:      "other kinds aren't touched",
+			recorded:  []ObjectKey{key("Deployment", "a"), key("Service", "a")},
+			manifests: []ObjectKey{key("Deployment", "b")},
+			want:      []ObjectKey{key("Deployment", "b"), key("Service", "a")},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			inventory := &Inventory{objects: make(map[ObjectKey]bool)}
+			for _, key := range test.recorded {
+				inventory.objects[key] = true
+			}
+
+			inventory.Update("Deployment", test.manifests, test.kept)
+
+			var got []ObjectKey
+			for key := range inventory.objects {
+				got = append(got, key)
+			}
+			sort.Slice(got, func(m, n int) bool {
+				return got[m].String() < got[n].String()
+			})
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
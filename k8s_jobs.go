@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"sort"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/pkg/errors"
@@ -12,8 +13,8 @@ import (
 )
 
 // ListJobs returns the list of Jobs
-func (c *Client) ListJobs(namespace string) (*batchv1.JobList, error) {
-	jobs, err := c.clientset.BatchV1().Jobs(namespace).List(metav1.ListOptions{})
+func (c *Client) ListJobs(namespace string, listOptions metav1.ListOptions) (*batchv1.JobList, error) {
+	jobs, err := c.clientset.BatchV1().Jobs(namespace).List(listOptions)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to retrieve Jobs")
 	}
@@ -21,9 +22,9 @@ func (c *Client) ListJobs(namespace string) (*batchv1.JobList, error) {
 	return jobs, nil
 }
 
-// DeleteJob deletes the given Job
-func (c *Client) DeleteJob(job batchv1.Job) error {
-	if err := c.clientset.BatchV1().Jobs(job.Namespace).Delete(job.Name, &metav1.DeleteOptions{}); err != nil {
+// DeleteJob deletes the given Job unless it changed since listing
+func (c *Client) DeleteJob(job batchv1.Job, dryRun bool) error {
+	if err := c.clientset.BatchV1().Jobs(job.Namespace).Delete(job.Name, DeleteOptions(&job, dryRun)); err != nil {
 		return errors.Wrap(err, "failed to delete Job")
 	}
 
@@ -61,14 +62,15 @@ func (j Jobs) Swap(m, n int) {
 }
 
 // JobAndPodCleaner deletes completed Jobs and attached Pods
-func (c *Client) JobAndPodCleaner(namespace string, maxCount int64, dryRun bool) error {
+func (c *Client) JobAndPodCleaner(namespace string, maxCount int64, opts CleanerOptions) error {
 
-	jobs, err := c.ListJobs(namespace)
+	jobs, err := c.ListJobs(namespace, opts.ListOptions())
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 
+	now := time.Now()
 	jobGroup := map[string]Jobs{}
 
 	for _, job := range jobs.Items {
@@ -89,7 +91,8 @@ func (c *Client) JobAndPodCleaner(namespace string, maxCount int64, dryRun bool)
 		jobGroup[label] = append(jobGroup[label], job)
 	}
 
-	pods, err := c.ListPods(namespace)
+	// Pods are deleted only together with selected Jobs, so only Pods of Jobs are listed
+	pods, err := c.ListPods(namespace, metav1.ListOptions{LabelSelector: jobNameLabel})
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
@@ -125,30 +128,82 @@ func (c *Client) JobAndPodCleaner(namespace string, maxCount int64, dryRun bool)
 				continue
 			}
 
-			if dryRun {
+			if age, young := opts.Grace.Young(&job, now); young {
+				color.Yellow("Skipping Job %s: created %s ago, younger than min age %s", job.Name, age.Round(time.Second), opts.Grace.MinAge)
+				continue
+			}
+
+			if reason := opts.Protection.Reason("Job", &job); reason != "" {
+				color.Red("You can't delete Job %s: %s", job.Name, reason)
+				continue
+			}
+
+			if opts.DryRun {
 				color.Yellow("******************************************************************************")
 				color.Yellow("Deleting Job %s  [dry-run]\n", job.Name)
 				color.Yellow("******************************************************************************")
+				opts.Plan.Add(&job, "batch/v1", "Job")
+				if opts.ServerDryRun {
+					ReportServerDryRun("Job", &job, c.DeleteJob(job, true))
+				}
 			} else {
 				color.Red("******************************************************************************")
 				color.Red("Deleting Job %s \n", job.Name)
 				color.Red("******************************************************************************")
-				if err := c.DeleteJob(job); err != nil {
+				if err := opts.Backup.WriteTyped(&job, "batch/v1", "Job"); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					os.Exit(1)
+				}
+				if err := c.DeleteJob(job, false); err != nil {
+					if err := opts.Backup.DiscardTyped(&job, "batch/v1", "Job"); err != nil {
+						fmt.Fprintln(os.Stderr, err)
+						os.Exit(1)
+					}
+					if IsChanged(err) {
+						color.Yellow("Job %s changed since listing, skipped", job.Name)
+						continue
+					}
 					fmt.Fprintln(os.Stderr, err)
 					os.Exit(1)
 				}
 			}
 
 			for _, pod := range podGroup[job.Name] {
-				if dryRun {
+				if reason := opts.Protection.Reason("Pod", &pod); reason != "" {
+					color.Red("You can't delete Pod %s: %s", pod.Name, reason)
+					continue
+				}
+
+				if opts.DryRun {
 					color.Yellow("******************************************************************************")
 					color.Yellow("  Deleting Pod %s [dry-run]\n", pod.Name)
 					color.Yellow("******************************************************************************")
+					opts.Plan.Add(&pod, "v1", "Pod")
+					if opts.ServerDryRun {
+						ReportServerDryRun("Pod", &pod, c.DeletePod(pod, true))
+					}
 				} else {
 					color.Red("******************************************************************************")
 					color.Red("  Deleting Pod %s\n", pod.Name)
 					color.Red("******************************************************************************")
-					if err := c.DeletePod(pod); err != nil {
+					// Garbage collector orphans Pods of the deleted Job, which changes their resourceVersion, so
+					// only UID of Pods owned by the Job is checked
+					if OwnedBy(&pod, job.UID) {
+						pod.ResourceVersion = ""
+					}
+					if err := opts.Backup.WriteTyped(&pod, "v1", "Pod"); err != nil {
+						fmt.Fprintln(os.Stderr, err)
+						os.Exit(1)
+					}
+					if err := c.DeletePod(pod, false); err != nil {
+						if err := opts.Backup.DiscardTyped(&pod, "v1", "Pod"); err != nil {
+							fmt.Fprintln(os.Stderr, err)
+							os.Exit(1)
+						}
+						if IsChanged(err) {
+							color.Yellow("Pod %s changed since listing, skipped", pod.Name)
+							continue
+						}
 						fmt.Fprintln(os.Stderr, err)
 						os.Exit(1)
 					}
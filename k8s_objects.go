@@ -0,0 +1,352 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// CleanerOptions holds settings shared by cleaners during a run
+type CleanerOptions struct {
+	DryRun bool
+	// Manifests are objects defined in VCS
+	Manifests *ManifestIndex
+	// Candidates restricts pruning to the given objects (e.g. removed from VCS in git range), all objects if nil
+	Candidates *ManifestIndex
+	// Inventory is the name of ConfigMap in each namespace recording objects applied by pipeline. If set,
+	// only objects recorded in inventory are pruned
+	Inventory string
+	// Protection decides which objects are never deleted
+	Protection Protection
+	// Selector is a label selector restricting listed objects
+	Selector string
+	// FieldSelector is a field selector restricting listed objects
+	FieldSelector string
+	// Grace delays pruning of new and just orphaned objects
+	Grace GracePolicy
+	// Quarantine makes pruning two-phase
+	Quarantine QuarantinePolicy
+	// ServerDryRun sends deletions of dry run to API server, which validates them without persisting
+	ServerDryRun bool
+	// Backup records live objects before deletion, nothing is recorded if nil
+	Backup *Backup
+	// Plan records objects which would be deleted by dry run, nothing is recorded if nil
+	Plan *Plan
+	// ResolveOwners makes prune decision of controlled objects on their root owners, controlled objects are
+	// skipped otherwise
+	ResolveOwners bool
+	// Deleted records objects deleted during the run by all cleaners
+	Deleted map[ObjectKey]bool
+	// Roots records root owners handled during the run, each of them is pruned once
+	Roots map[ObjectKey]bool
+	// Detectors recognize objects managed by GitOps tools, which are never pruned
+	Detectors []OwnershipDetector
+	// Summary counts results of the run
+	Summary *Summary
+}
+
+// ListOptions returns options of listing objects restricted by selectors
+func (o CleanerOptions) ListOptions() metav1.ListOptions {
+	return metav1.ListOptions{LabelSelector: o.Selector, FieldSelector: o.FieldSelector}
+}
+
+// Scoped returns whether listed objects are restricted by selectors
+func (o CleanerOptions) Scoped() bool {
+	return o.Selector != "" || o.FieldSelector != ""
+}
+
+// Selects returns whether labels of the given object match label selector, so manifests are restricted
+// the same way as listed objects
+func (o CleanerOptions) Selects(object metav1.Object) bool {
+	selector, err := labels.Parse(o.Selector)
+	if err != nil {
+		return true
+	}
+
+	return selector.Matches(labels.Set(object.GetLabels()))
+}
+
+// ListObjects returns the list of objects of the given kind
+func (c *Client) ListObjects(namespace string, mapping *meta.RESTMapping, listOptions metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	objects, err := c.dynamic.Resource(mapping.Resource).Namespace(namespace).List(listOptions)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to retrieve %s", mapping.Resource.Resource)
+	}
+
+	return objects, nil
+}
+
+// DeleteOptions returns options deleting the given object only if it wasn't re-created or modified since listing.
+// Dry-run deletion is validated by API server without persisting
+func DeleteOptions(object metav1.Object, dryRun bool) *metav1.DeleteOptions {
+	preconditions := &metav1.Preconditions{}
+	if uid := object.GetUID(); uid != "" {
+		preconditions.UID = &uid
+	}
+	if resourceVersion := object.GetResourceVersion(); resourceVersion != "" {
+		preconditions.ResourceVersion = &resourceVersion
+	}
+
+	options := &metav1.DeleteOptions{Preconditions: preconditions}
+	if dryRun {
+		options.DryRun = []string{metav1.DryRunAll}
+	}
+
+	return options
+}
+
+// IsChanged returns whether deletion failed because object was deleted, re-created or modified since listing
+func IsChanged(err error) bool {
+	cause := errors.Cause(err)
+
+	return apierrors.IsConflict(cause) || apierrors.IsNotFound(cause)
+}
+
+// DeleteObject deletes the given object unless it changed since listing
+func (c *Client) DeleteObject(mapping *meta.RESTMapping, object unstructured.Unstructured, dryRun bool) error {
+	if err := c.dynamic.Resource(mapping.Resource).Namespace(object.GetNamespace()).Delete(object.GetName(), DeleteOptions(&object, dryRun)); err != nil {
+		return errors.Wrapf(err, "failed to delete %s", mapping.GroupVersionKind.Kind)
+	}
+
+	return nil
+}
+
+// PatchObject applies the given merge patch to object, nil values remove fields
+func (c *Client) PatchObject(mapping *meta.RESTMapping, object unstructured.Unstructured, patch map[string]interface{}) error {
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode patch")
+	}
+
+	if _, err := c.dynamic.Resource(mapping.Resource).Namespace(object.GetNamespace()).Patch(object.GetName(), types.MergePatchType, data, metav1.PatchOptions{}); err != nil {
+		return errors.Wrapf(err, "failed to patch %s %s", mapping.GroupVersionKind.Kind, object.GetName())
+	}
+
+	return nil
+}
+
+// ObjectsCleaner deletes all objects of the given kind in k8s cluster which are absent in VCS
+func (c *Client) ObjectsCleaner(namespace string, mapping *meta.RESTMapping, opts CleanerOptions) error {
+	var left []unstructured.Unstructured
+	kind := mapping.GroupVersionKind.Kind
+
+	clusterObjects, err := c.ListObjects(namespace, mapping, opts.ListOptions())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	var inventory *Inventory
+	if opts.Inventory != "" {
+		inventory, err = c.GetInventory(namespace, opts.Inventory)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	// Put objects which are candidates for pruning to left slice for future comparing. Controlled objects are never
+	// recorded in inventory or manifests, so with resolved owners their root owner is checked instead
+	for _, value := range clusterObjects.Items {
+		if opts.ResolveOwners && ControllerOf(&value) != "" {
+			left = append(left, value)
+			continue
+		}
+		if opts.Candidates != nil && !opts.Candidates.Has(KeyOf(&value)) {
+			continue
+		}
+		if inventory != nil && !inventory.Has(KeyOf(&value)) {
+			continue
+		}
+		left = append(left, value)
+	}
+
+	now := time.Now()
+
+	// Objects which are back in VCS aren't orphaned anymore
+	for _, object := range clusterObjects.Items {
+		if !opts.Manifests.Has(KeyOf(&object)) {
+			continue
+		}
+		if IsOrphaned(&object) {
+			color.Cyan("%s %s is back in VCS, orphaned mark is removed", kind, object.GetName())
+			if !opts.DryRun {
+				if err := c.UnmarkOrphan(mapping, object); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					os.Exit(1)
+				}
+			}
+		}
+		if InQuarantine(&object) {
+			color.Cyan("%s %s is back in VCS, it is released from quarantine", kind, object.GetName())
+			if !opts.DryRun {
+				if err := c.Release(mapping, object); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					os.Exit(1)
+				}
+			}
+		}
+	}
+
+	// Delete objects in k8s cluster which are absent in VCS
+	deleted := make(map[ObjectKey]bool)
+	for _, object := range Except(left, opts.Manifests) {
+		if owner := ControllerOf(&object); owner != "" && !opts.ResolveOwners {
+			color.Yellow("Skipping %s %s: controlled by %s", kind, object.GetName(), owner)
+			continue
+		}
+		if detector := DetectOwner(opts.Detectors, &object); detector != "" {
+			color.Yellow("Skipping %s %s: managed by %s", kind, object.GetName(), detector)
+			opts.Summary.Exclude(detector)
+			continue
+		}
+		if reason := opts.Protection.Reason(kind, &object); reason != "" {
+			color.Red("You can't delete %s %s: %s", kind, object.GetName(), reason)
+			continue
+		}
+		if ControllerOf(&object) != "" {
+			c.RootCleaner(kind, object, inventory, opts)
+			continue
+		}
+		if opts.Deleted[KeyOf(&object)] {
+			continue
+		}
+
+		if c.PruneObject(mapping, object, fmt.Sprintf("%s %s", kind, object.GetName()), opts, now) {
+			deleted[KeyOf(&object)] = true
+		}
+	}
+
+	// Record objects applied from VCS and objects which are still kept in cluster
+	if inventory != nil && !opts.DryRun {
+		var kept []ObjectKey
+		for _, object := range clusterObjects.Items {
+			if key := KeyOf(&object); !deleted[key] {
+				kept = append(kept, key)
+			}
+		}
+		// Objects out of selectors weren't listed, so they stay recorded
+		if opts.Scoped() {
+			for _, key := range inventory.Keys(kind) {
+				if !deleted[key] {
+					kept = append(kept, key)
+				}
+			}
+		}
+		inventory.Update(kind, opts.Manifests.Keys(namespace, kind), kept)
+		if err := c.SaveInventory(inventory); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	return nil
+}
+
+// PruneObject deletes the given object absent in VCS unless it is younger than min age, waits for grace period
+// or stays in quarantine. Description names object in output. It returns whether object was deleted
+func (c *Client) PruneObject(mapping *meta.RESTMapping, object unstructured.Unstructured, description string, opts CleanerOptions, now time.Time) bool {
+	kind := mapping.GroupVersionKind.Kind
+
+	if age, young := opts.Grace.Young(&object, now); young {
+		color.Yellow("Skipping %s: created %s ago, younger than min age %s", description, age.Round(time.Second), opts.Grace.MinAge)
+		return false
+	}
+
+	if opts.Grace.Enabled() {
+		orphan := NextOrphan(&object, now)
+		if !opts.Grace.Ready(orphan, now) {
+			color.Yellow("Skipping %s: absent in VCS since %s (%d runs), waiting for grace period", description, orphan.Since.Format(time.RFC3339), orphan.Runs)
+			if !opts.DryRun {
+				if err := c.MarkOrphan(mapping, object, orphan); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					os.Exit(1)
+				}
+			}
+			return false
+		}
+	}
+
+	if opts.Quarantine.Enabled {
+		if QuarantineCancelled(&object) {
+			color.Yellow("Skipping %s: quarantine was cancelled", description)
+			return false
+		}
+		if CancelRequested(&object) {
+			color.Yellow("Skipping %s: quarantine was cancelled by removing label %s or annotation %s, it is released", description, quarantineLabel, markedAtAnnotation)
+			if !opts.DryRun {
+				if err := c.CancelQuarantine(mapping, object, now); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					os.Exit(1)
+				}
+			}
+			return false
+		}
+		markedAt, marked := MarkedAt(&object)
+		if !marked {
+			if opts.DryRun {
+				color.Yellow("******************************************************************************")
+				color.Yellow("  Quarantining %s [dry-run]\n", description)
+				color.Yellow("******************************************************************************")
+			} else {
+				color.Red("******************************************************************************")
+				color.Red("  Quarantining %s\n", description)
+				color.Red("******************************************************************************")
+				if err := c.Quarantine(mapping, object, now); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					os.Exit(1)
+				}
+			}
+			return false
+		}
+		if now.Sub(markedAt) < opts.Quarantine.Grace {
+			color.Yellow("Skipping %s: quarantined at %s, waiting for grace period", description, markedAt.Format(time.RFC3339))
+			return false
+		}
+	}
+
+	if opts.DryRun {
+		color.Yellow("******************************************************************************")
+		color.Yellow("  Deleting %s [dry-run]\n", description)
+		color.Yellow("******************************************************************************")
+		opts.Plan.Add(&object, object.GetAPIVersion(), kind)
+		if opts.ServerDryRun {
+			ReportServerDryRun(kind, &object, c.DeleteObject(mapping, object, true))
+		}
+	} else {
+		color.Red("******************************************************************************")
+		color.Red("  Deleting %s\n", description)
+		color.Red("******************************************************************************")
+		if err := opts.Backup.Write(&object); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if err := c.DeleteObject(mapping, object, false); err != nil {
+			if err := opts.Backup.Discard(&object); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			if IsChanged(err) {
+				color.Yellow("%s changed since listing, skipped", description)
+				return false
+			}
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	if opts.Deleted != nil {
+		opts.Deleted[KeyOf(&object)] = true
+	}
+
+	return !opts.DryRun
+}
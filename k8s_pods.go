@@ -11,8 +11,8 @@ const (
 )
 
 // ListPods returns the list of Pods
-func (c *Client) ListPods(namespace string) (*corev1.PodList, error) {
-	pods, err := c.clientset.CoreV1().Pods(namespace).List(metav1.ListOptions{})
+func (c *Client) ListPods(namespace string, listOptions metav1.ListOptions) (*corev1.PodList, error) {
+	pods, err := c.clientset.CoreV1().Pods(namespace).List(listOptions)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to retrieve Pods")
 	}
@@ -20,9 +20,9 @@ func (c *Client) ListPods(namespace string) (*corev1.PodList, error) {
 	return pods, nil
 }
 
-// DeletePod deletes the given Pod
-func (c *Client) DeletePod(pod corev1.Pod) error {
-	if err := c.clientset.CoreV1().Pods(pod.Namespace).Delete(pod.Name, &metav1.DeleteOptions{}); err != nil {
+// DeletePod deletes the given Pod unless it changed since listing
+func (c *Client) DeletePod(pod corev1.Pod, dryRun bool) error {
+	if err := c.clientset.CoreV1().Pods(pod.Namespace).Delete(pod.Name, DeleteOptions(&pod, dryRun)); err != nil {
 		return errors.Wrap(err, "failed to delete Pod")
 	}
 
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/krusty"
+)
+
+// CollectObjectsFromKustomization builds the given kustomization (e.g. overlay) in-process and puts resulting objects
+// to index, so names with namePrefix/nameSuffix and generated names with hash suffixes are compared
+func CollectObjectsFromKustomization(path, namespace string, index *ManifestIndex) error {
+	kustomizer := krusty.MakeKustomizer(filesys.MakeFsOnDisk(), krusty.MakeDefaultOptions())
+
+	resMap, err := kustomizer.Run(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to build kustomization %s", path)
+	}
+
+	for i, res := range resMap.Resources() {
+		index.Add(&unstructured.Unstructured{Object: res.Map()}, namespace, Location{Source: path, Document: i})
+	}
+
+	return nil
+}
@@ -3,51 +3,199 @@ package main
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/fatih/color"
+	"github.com/pkg/errors"
 	flag "github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
 const (
-	defaultMaxCount  = 10
-	acceptedK8sKinds = `(Service|StatefulSet|Deployment|CronJob|LimitRange|DaemonSet)`
-	defaultKind      = "All"
-	debug            = false
+	defaultMaxCount = 10
+	defaultKind     = "All"
+	jobsKind        = "Jobs"
+	helmHistoryKind = "HelmHistory"
+
+	defaultHelmHistoryMax  = 10
+	defaultQuarantineGrace = 24 * time.Hour
+
+	commandPrune   = "prune"
+	commandDrift   = "drift"
+	commandDiff    = "diff"
+	commandRestore = "restore"
+	commandPlan    = "plan"
+	commandApply   = "apply"
+)
+
+// commands are run by the first argument, prune by default
+var commands = []string{commandPrune, commandDrift, commandDiff, commandPlan, commandApply, commandRestore}
+
+var (
+	// defaultKinds are cleaned when selected kind is All. HelmHistory is cleaned only if it is selected or listed
+	// in kinds of config
+	defaultKinds = []string{"Deployment", "Service", "CronJob", "StatefulSet", "DaemonSet", "LimitRange", jobsKind}
+	// defaultProtectedObjects are never deleted unless --protect is set, protect rules of config are added to them
+	defaultProtectedObjects = []string{"Service/kubernetes", "CronJob/cert-manager-webhook-ca-sync", "LimitRange/limits"}
 )
 
+// kindCleaner describes cleaning of a kind in namespace
+type kindCleaner struct {
+	// kind as it is selected
+	kind string
+	// mapping of kind, nil for Jobs and HelmHistory
+	mapping *meta.RESTMapping
+	// protect rules of kind in namespace
+	protect []ProtectRule
+	// selectors of kind in namespace
+	selector      string
+	fieldSelector string
+}
+
 func main() {
 	var (
+		configPath           string
 		kubeconfig           string
 		context              string
 		kind                 string
 		maxCount             int64
-		dryRun               bool
-		restrictedNamespaces = []string{"kube-system", "kube-public", "kube-node-lease", "spinnaker"}
+		helmHistoryMax       int64
+		dryRun               string
+		strict               bool
+		defaultNamespace     string
+		releaseName          string
+		gitSource            GitSource
+		gitRange             string
+		inventory            string
+		protectSelector      string
+		selector             string
+		grace                GracePolicy
+		quarantine           QuarantinePolicy
+		resolveOwners        bool
+		detectorNames        []string
+		helmReleases         bool
+		diffOutput           string
+		backupDir            string
+		backupFormat         string
+		runID                string
+		planPath             string
+		fieldSelector        string
+		releaseNamespace     string
+		restrictedNamespaces = []string{"kube-system", "kube-public", "kube-node-lease"}
 		defaultNamespaces    = []string{"default", "cert-manager", "logging", "monitoring"}
 	)
 
 	flags := flag.NewFlagSet("k8stail", flag.ExitOnError)
 	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: k8s-cleaner [%s] [flags]\n", strings.Join(commands, "|"))
 		flags.PrintDefaults()
 	}
 
+	flags.StringVar(&configPath, "config", "", "Path of YAML config file, flags and "+envPrefix+"* environment variables override its settings")
 	flags.StringVar(&kubeconfig, "kubeconfig", "", "Path of kubeconfig")
 	flags.StringVar(&context, "context", "", "Kubernetes context")
 	flags.StringSlice("namespaces", defaultNamespaces, "List namespaces separated by commas")
-	flags.StringVar(&kind, "kind", string(defaultKind), "Kubernetes kind for cleaning. Can be one of Service|StatefulSet|Deployment|CronJob|LimitRange|DaemonSet|Jobs or All")
-	flags.BoolVar(&dryRun, "dry-run", true, "Dry run")
+	flags.StringVar(&kind, "kind", string(defaultKind), "Kubernetes kind for cleaning. Can be any namespaced kind, short name or kind.group known to the cluster, Jobs, HelmHistory or All")
+	flags.StringVar(&dryRun, "dry-run", string(dryRunClient), "Dry run mode: client (or true) prints objects which would be deleted, server also validates deletions by API server, none (or false) deletes objects")
+	flags.Lookup("dry-run").NoOptDefVal = string(dryRunClient)
+	flags.BoolVar(&strict, "strict", false, "Abort before any deletion if some manifest can't be read or decoded (default true if --dry-run=false)")
+	flags.StringVar(&inventory, "inventory", "", "Name of ConfigMap in each namespace recording objects applied from VCS, only recorded objects are pruned if set")
+	flags.StringVarP(&selector, "selector", "l", "", "Label selector restricting listed objects, e.g. team=payments,app.kubernetes.io/managed-by!=Helm")
+	flags.StringVar(&fieldSelector, "field-selector", "", "Field selector restricting listed objects, e.g. metadata.name!=kubernetes")
+	flags.StringVar(&protectSelector, "protect-selector", "", "Label selector of objects and namespaces which are never cleaned, in addition to "+protectAnnotation+"=true annotation")
+	flags.StringSlice("protect", defaultProtectedObjects, "Objects which are never deleted in Kind/name form separated by commas")
 	flags.Int64Var(&maxCount, "max-count", int64(defaultMaxCount), "Number of Jobs to remain, only if selected kind is Jobs")
+	flags.DurationVar(&grace.MinAge, "min-age", 0, "Minimum age of objects by creationTimestamp to be deleted, e.g. 1h")
+	flags.DurationVar(&grace.Period, "orphan-grace", 0, "Minimum time objects have to stay absent in VCS to be deleted, e.g. 24h")
+	flags.Int64Var(&grace.Runs, "orphan-runs", 0, "Minimum number of consecutive runs objects have to stay absent in VCS to be deleted")
+	flags.BoolVar(&quarantine.Enabled, "quarantine", false, "Quarantine objects absent in VCS first (scale to zero, suspend) and delete them by a later run after --quarantine-grace")
+	flags.DurationVar(&quarantine.Grace, "quarantine-grace", defaultQuarantineGrace, "Minimum time objects stay in quarantine before deletion")
+	flags.BoolVar(&resolveOwners, "resolve-owners", false, "Decide on root owners of controlled objects absent in VCS instead of skipping them, root owners absent in VCS are deleted")
+	flags.StringSliceVar(&detectorNames, "detectors", DetectorNames(), "Ownership detectors of GitOps tools separated by commas, objects managed by them are never deleted, empty to turn off")
+	flags.Int64Var(&helmHistoryMax, "helm-history-max", int64(defaultHelmHistoryMax), "Number of revisions of every Helm release to remain besides the deployed one, only if selected kind is HelmHistory")
+	flags.StringVar(&diffOutput, "diff-output", diffOutputUnified, "Output of diff command, unified or structured (JSON line per object)")
+	flags.StringVar(&backupDir, "backup-dir", "", "Directory objects are backed up to before deletion by run ID/namespace/kind/name, and restored from by restore command")
+	flags.StringVar(&backupFormat, "backup-format", backupFormatDir, "Format of backup, dir or tar.gz (tarball per run)")
+	flags.StringVar(&planPath, "plan", "", "Path of deletion plan written by plan command and applied by apply command")
+	flags.StringVar(&runID, "run", "", "Run ID of backup restored by restore command")
 	flags.StringSlice("directories", nil, "Paths to directories with manifests separated by commas")
+	flags.StringVar(&defaultNamespace, "default-namespace", "default", "Namespace for manifests without metadata.namespace")
+	flags.StringSlice("charts", nil, "Paths to local Helm charts rendered as manifests separated by commas")
+	flags.StringSlice("values", nil, "Values files for Helm charts separated by commas, the last file wins")
+	flags.StringVar(&gitSource.Repository, "git-repository", "", "Path to local git repository (bare or working copy) manifests are read from, --directories are paths inside it then")
+	flags.StringVar(&gitSource.Revision, "git-revision", defaultGitRevision, "Branch, tag or commit of --git-repository manifests are read at")
+	flags.StringVar(&gitRange, "git-range", "", "Revision range of --git-repository (A..B or A...B from merge-base), only objects deleted or renamed in it are pruned")
+	flags.BoolVar(&helmReleases, "helm-releases", false, "Use manifests of deployed Helm releases stored in cluster as manifests too")
+	flags.StringSlice("kustomize", nil, "Paths to kustomizations (e.g. overlays) built as manifests separated by commas")
+	flags.StringVar(&releaseName, "release-name", "", "Helm release name used for rendering charts, chart name by default")
+	flags.StringVar(&releaseNamespace, "release-namespace", "", "Helm release namespace used for rendering charts, --default-namespace by default")
+	flags.StringToString("directory-namespaces", nil, "Namespaces for manifests without metadata.namespace per directory, e.g. /path/to/monitoring=monitoring")
 
 	if err := flags.Parse(os.Args[1:]); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 
-	dirs, err := flags.GetStringSlice("directories")
-	if len(dirs) == 0 {
-		color.Red("No directories for analyze, exit")
+	command := commandPrune
+	if flags.NArg() > 0 {
+		command = flags.Arg(0)
+	}
+	if !stringInSlice(command, commands) || flags.NArg() > 1 {
+		fmt.Fprintf(os.Stderr, "unknown command %s, supported commands are %s\n", strings.Join(flags.Args(), " "), strings.Join(commands, ", "))
+		os.Exit(1)
+	}
+
+	if err := ApplyEnvironment(flags); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	config, err := LoadConfig(configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	// Settings which are set explicitly override rules of config too
+	maxCountExplicit := flags.Changed("max-count")
+	protectExplicit := flags.Changed("protect")
+	chartsExplicit := flags.Changed("charts")
+
+	if err := config.ApplyToFlags(flags); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	dryRunMode, err := ParseDryRun(dryRun)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if diffOutput != diffOutputUnified && diffOutput != diffOutputStructured {
+		fmt.Fprintf(os.Stderr, "unknown diff output %s, supported outputs are %s, %s\n", diffOutput, diffOutputUnified, diffOutputStructured)
+		os.Exit(1)
+	}
+	// Structured diff is a stream of JSON lines, so human-readable output goes to stderr
+	if command == commandDiff && diffOutput == diffOutputStructured {
+		color.Output = color.Error
+	}
+
+	if len(config.RestrictedNamespaces) > 0 {
+		restrictedNamespaces = config.RestrictedNamespaces
+	}
+
+	if _, err := labels.Parse(selector); err != nil {
+		fmt.Fprintln(os.Stderr, errors.Wrap(err, "invalid selector"))
+		os.Exit(1)
+	}
+	if _, err := fields.ParseSelector(fieldSelector); err != nil {
+		fmt.Fprintln(os.Stderr, errors.Wrap(err, "invalid field selector"))
 		os.Exit(1)
 	}
 
@@ -69,16 +217,406 @@ func main() {
 
 	client = c
 
+	if command == commandApply {
+		if planPath == "" {
+			color.Red("--plan is required by apply command, exit")
+			os.Exit(1)
+		}
+		plan, err := LoadPlan(planPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		contextName, err := client.ContextName()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if plan.Context != contextName {
+			color.Red("Plan was made in context %s, but current context is %s, exit", plan.Context, contextName)
+			os.Exit(1)
+		}
+
+		// Applying a plan deletes objects unless dry run is requested explicitly
+		opts := CleanerOptions{DryRun: flags.Changed("dry-run") && dryRunMode != dryRunNone}
+		opts.ServerDryRun = opts.DryRun && dryRunMode == dryRunServer
+		if backupDir != "" && !opts.DryRun {
+			opts.Backup, err = NewBackup(backupDir, backupFormat, time.Now())
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			color.Cyan("Backup run ID: %s\n", opts.Backup.RunID)
+		}
+
+		color.Cyan("Applying plan %s made at %s\n", planPath, plan.CreatedAt.Format(time.RFC3339))
+		for source, revision := range plan.Revisions {
+			color.Cyan("Manifests revision: %s %s\n", source, revision)
+		}
+		client.ApplyPlan(plan, opts)
+		if err := opts.Backup.Close(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if command == commandRestore {
+		if runID == "" {
+			color.Red("--run is required by restore command, exit")
+			os.Exit(1)
+		}
+		objects, err := ReadBackup(backupDir, runID)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		filter := RestoreFilter{}
+		if flags.Changed("namespaces") {
+			filter.Namespaces, err = flags.GetStringSlice("namespaces")
+		}
+		if kind != defaultKind {
+			switch kind {
+			case jobsKind:
+				filter.Kind = "Job"
+			case helmHistoryKind:
+				filter.Kind = "Secret"
+			default:
+				mapping, err := client.ResolveKind(kind)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					os.Exit(1)
+				}
+				filter.Kind = mapping.GroupVersionKind.Kind
+			}
+		}
+		if selector != "" {
+			labelSelector, _ := labels.Parse(selector)
+			filter.Matches = func(object *unstructured.Unstructured) bool {
+				return labelSelector.Matches(labels.Set(object.GetLabels()))
+			}
+		}
+
+		color.Cyan("Restoring run %s from %s\n", runID, backupDir)
+		if err := client.RestoreObjects(objects, filter, dryRunMode != dryRunNone); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	dirs, err := flags.GetStringSlice("directories")
+	charts, err := flags.GetStringSlice("charts")
+	valuesFiles, err := flags.GetStringSlice("values")
+	kustomizations, err := flags.GetStringSlice("kustomize")
+
+	var helmCharts []HelmChart
+	if releaseNamespace == "" {
+		releaseNamespace = defaultNamespace
+	}
+	for _, chart := range charts {
+		helmCharts = append(helmCharts, HelmChart{
+			Path:        chart,
+			ReleaseName: releaseName,
+			Namespace:   releaseNamespace,
+			ValuesFiles: valuesFiles,
+		})
+	}
+	if !chartsExplicit {
+		for _, helmChart := range config.Charts {
+			if helmChart.Namespace == "" {
+				helmChart.Namespace = defaultNamespace
+			}
+			helmCharts = append(helmCharts, helmChart)
+		}
+	}
+
+	if len(dirs) == 0 && len(helmCharts) == 0 && len(kustomizations) == 0 && gitSource.Repository == "" {
+		color.Red("No directories, charts or kustomizations for analyze, exit")
+		os.Exit(1)
+	}
+
+	dirNamespaces := config.DirectoryNamespaces()
+	flagDirNamespaces, err := flags.GetStringToString("directory-namespaces")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	for directory, namespace := range flagDirNamespaces {
+		dirNamespaces[directory] = namespace
+	}
+
+	manifestDirs := ManifestDirs{
+		Paths:            dirs,
+		DefaultNamespace: defaultNamespace,
+		Namespaces:       dirNamespaces,
+	}
+
+	// Source without manifests (e.g. a wrong path) makes every object in cluster look absent in VCS
+	index := NewManifestIndex()
+	var emptySources []string
+	collected := func(source string, sourceIndex *ManifestIndex) {
+		if sourceIndex.Len() == 0 {
+			emptySources = append(emptySources, source)
+		}
+		index.Merge(sourceIndex)
+	}
+
+	var candidates *ManifestIndex
+	if gitRange != "" {
+		if gitSource.Repository == "" {
+			color.Red("--git-range requires --git-repository, exit")
+			os.Exit(1)
+		}
+		candidates = NewManifestIndex()
+		gitIndex := NewManifestIndex()
+		base, head, err := CollectRemovedObjectsFromGit(gitSource.Repository, gitRange, manifestDirs, gitIndex, candidates)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		collected(gitSource.Repository, gitIndex)
+		color.Cyan("Manifests revision: %s %s..%s (%s)\n", gitSource.Repository, base, head, gitRange)
+		color.Cyan("Objects removed in range: %d\n", candidates.Len())
+	} else if gitSource.Repository != "" {
+		gitIndex := NewManifestIndex()
+		sha, err := CollectObjectsFromGit(gitSource, manifestDirs, gitIndex)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		collected(gitSource.Repository, gitIndex)
+		color.Cyan("Manifests revision: %s %s (%s)\n", gitSource.Repository, sha, gitSource.Revision)
+	} else if len(dirs) > 0 {
+		dirIndex := NewManifestIndex()
+		if err := CollectObjectsFromDir(manifestDirs, dirIndex); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		collected(strings.Join(dirs, ","), dirIndex)
+	}
+
+	for _, kustomization := range kustomizations {
+		namespace := manifestDirs.NamespaceFor(filepath.Clean(kustomization))
+		kustomizationIndex := NewManifestIndex()
+		if err := CollectObjectsFromKustomization(kustomization, namespace, kustomizationIndex); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		collected(kustomization, kustomizationIndex)
+	}
+
+	var chartReleases []HelmRelease
+	for _, helmChart := range helmCharts {
+		chartIndex := NewManifestIndex()
+		chartRelease, err := CollectObjectsFromChart(helmChart, chartIndex)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		collected(helmChart.Path, chartIndex)
+		chartReleases = append(chartReleases, chartRelease)
+	}
+
+	detectors, err := NewOwnershipDetectors(detectorNames)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	protectedObjects, err := flags.GetStringSlice("protect")
+	protection, err := NewProtection(protectSelector, protectedObjects)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if !protectExplicit {
+		protection = protection.With(config.Protect)
+	}
+
+	if !flags.Changed("strict") {
+		strict = command == commandPlan || (dryRunMode == dryRunNone && command == commandPrune)
+	}
+
 	namespaces, err := flags.GetStringSlice("namespaces")
 	if len(namespaces) == 0 {
 		namespaces = defaultNamespaces
 	}
+
+	if helmReleases {
+		releaseIndex := NewManifestIndex()
+		var releases []HelmRelease
+		for _, namespace := range namespaces {
+			if stringInSlice(namespace, restrictedNamespaces) {
+				continue
+			}
+			namespaceReleases, err := client.CollectObjectsFromHelmReleases(namespace, releaseIndex)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			releases = append(releases, namespaceReleases...)
+		}
+		index.MergeMissing(releaseIndex)
+		color.Cyan("Helm releases in cluster: %d\n", len(releases))
+		ReportHelmReleases(releases, chartReleases)
+	}
+
+	parseErrors := index.Errors()
+	ReportParseErrors(parseErrors)
+	if strict && len(parseErrors) > 0 {
+		color.Red("Strict mode: aborting before any deletion\n")
+		os.Exit(1)
+	}
+
+	for _, source := range emptySources {
+		color.Red("No manifests found in %s", source)
+	}
+	if len(emptySources) > 0 && (command == commandPlan || (command == commandPrune && dryRunMode == dryRunNone)) {
+		color.Red("Refusing to prune: every object would look absent in VCS\n")
+		os.Exit(1)
+	}
+
+	client.ReportUnsupportedKinds(index)
+
+	// Kinds of config rules are matched by resolved kind, kinds unknown to the cluster only by name
+	kindKey := func(kind string) string {
+		if kind == jobsKind || kind == helmHistoryKind {
+			return kind
+		}
+		mapping, err := client.ResolveKind(kind)
+		if err != nil {
+			return kind
+		}
+		return mapping.GroupVersionKind.GroupKind().String()
+	}
+
+	// Resolve kinds of all namespaces before cleaning anything
+	mappings := make(map[string]*meta.RESTMapping)
+	cleaners := make(map[string][]kindCleaner)
+	for _, namespace := range namespaces {
+		rules := []KindRule{config.KindRule(namespace, kind, kindKey)}
+		if kind == defaultKind {
+			rules = config.KindRules(namespace, defaultKinds, kindKey)
+		}
+
+		for _, rule := range rules {
+			cleaner := kindCleaner{
+				kind:          rule.Kind,
+				protect:       rule.Protect,
+				selector:      JoinSelectors(selector, rule.Selector),
+				fieldSelector: JoinSelectors(fieldSelector, rule.FieldSelector),
+			}
+			if rule.Kind != jobsKind && rule.Kind != helmHistoryKind {
+				if _, ok := mappings[rule.Kind]; !ok {
+					mapping, err := client.ResolveKind(rule.Kind)
+					if err != nil {
+						fmt.Fprintln(os.Stderr, err)
+						os.Exit(1)
+					}
+					mappings[rule.Kind] = mapping
+				}
+				cleaner.mapping = mappings[rule.Kind]
+			}
+			cleaners[namespace] = append(cleaners[namespace], cleaner)
+		}
+	}
+
+	opts := CleanerOptions{
+		DryRun:        dryRunMode != dryRunNone,
+		ServerDryRun:  dryRunMode == dryRunServer,
+		Manifests:     index,
+		Candidates:    candidates,
+		Inventory:     inventory,
+		Protection:    protection,
+		Grace:         grace,
+		Quarantine:    quarantine,
+		ResolveOwners: resolveOwners,
+		Deleted:       make(map[ObjectKey]bool),
+		Roots:         make(map[ObjectKey]bool),
+		Detectors:     detectors,
+		Summary:       NewSummary(detectors),
+	}
+
+	if command == commandPlan {
+		if planPath == "" {
+			color.Red("--plan is required by plan command, exit")
+			os.Exit(1)
+		}
+		contextName, err := client.ContextName()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		opts.DryRun = true
+		opts.Plan = NewPlan(contextName, namespaces, index.Revisions(), time.Now())
+	}
+
+	if command == commandPrune && backupDir != "" && !opts.DryRun {
+		opts.Backup, err = NewBackup(backupDir, backupFormat, time.Now())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		color.Cyan("Backup run ID: %s\n", opts.Backup.RunID)
+	}
+
+	drifted := false
 	for _, namespace := range namespaces {
 		color.Cyan("     === NAMESPACE %s\n", namespace)
 		if stringInSlice(namespace, restrictedNamespaces) {
 			color.Red("You can't manage namespace %s\n", namespace)
 			continue
 		}
+		reason, err := client.NamespaceProtection(namespace, protection.With(config.NamespaceProtect(namespace)))
+		if err != nil {
+			color.Red("You can't manage namespace %s: %s\n", namespace, err)
+			continue
+		}
+		if reason != "" {
+			color.Red("You can't manage namespace %s: %s\n", namespace, reason)
+			continue
+		}
+		if command == commandDrift {
+			for _, cleaner := range cleaners[namespace] {
+				if cleaner.mapping == nil {
+					continue
+				}
+				kindOpts := opts
+				kindOpts.Selector = cleaner.selector
+				kindOpts.FieldSelector = cleaner.fieldSelector
+
+				drift, err := client.KindDrift(namespace, cleaner.mapping, kindOpts)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					os.Exit(1)
+				}
+				drift.Print(index)
+				drifted = drifted || drift.Drifted()
+			}
+			continue
+		}
+
+		if command == commandDiff {
+			for _, cleaner := range cleaners[namespace] {
+				if cleaner.mapping == nil {
+					continue
+				}
+				kindOpts := opts
+				kindOpts.Selector = cleaner.selector
+				kindOpts.FieldSelector = cleaner.fieldSelector
+
+				kindDrifted, err := client.KindDiff(namespace, cleaner.mapping, kindOpts, diffOutput)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					os.Exit(1)
+				}
+				drifted = drifted || kindDrifted
+			}
+			continue
+		}
+
 		// if namespace == "" {
 		// 	namespaceInConfig, err := c.NamespaceInConfig()
 		// 	if err != nil {
@@ -92,31 +630,52 @@ func main() {
 		// 	}
 		// }
 
-		switch kind {
-		case "Service":
-			client.ServicesCleaner(namespace, dryRun, dirs)
-		case "StatefulSet":
-			client.StatefulSetsCleaner(namespace, dryRun, dirs)
-		case "Deployment":
-			client.DeploymentsCleaner(namespace, dryRun, dirs)
-		case "CronJob":
-			client.CronJobsCleaner(namespace, dryRun, dirs)
-		case "LimitRange":
-			client.LimitRangesCleaner(namespace, dryRun, dirs)
-		case "DaemonSet":
-			client.DaemonSetsCleaner(namespace, dryRun, dirs)
-		case "Jobs":
-			client.JobAndPodCleaner(namespace, maxCount, dryRun)
-		case "All":
-			client.DeploymentsCleaner(namespace, dryRun, dirs)
-			client.ServicesCleaner(namespace, dryRun, dirs)
-			client.CronJobsCleaner(namespace, dryRun, dirs)
-			client.StatefulSetsCleaner(namespace, dryRun, dirs)
-			client.DaemonSetsCleaner(namespace, dryRun, dirs)
-			client.LimitRangesCleaner(namespace, dryRun, dirs)
-			client.JobAndPodCleaner(namespace, maxCount, dryRun)
+		namespaceProtection := protection.With(config.NamespaceProtect(namespace))
+		namespaceMaxCount := maxCount
+		if !maxCountExplicit {
+			namespaceMaxCount = config.MaxCountFor(namespace, maxCount)
+		}
+
+		for _, cleaner := range cleaners[namespace] {
+			kindOpts := opts
+			kindOpts.Protection = namespaceProtection.With(cleaner.protect)
+			kindOpts.Selector = cleaner.selector
+			kindOpts.FieldSelector = cleaner.fieldSelector
+
+			switch cleaner.kind {
+			case jobsKind:
+				client.JobAndPodCleaner(namespace, namespaceMaxCount, kindOpts)
+			case helmHistoryKind:
+				client.HelmHistoryCleaner(namespace, helmHistoryMax, kindOpts)
+			default:
+				client.ObjectsCleaner(namespace, cleaner.mapping, kindOpts)
+			}
+		}
+	}
+
+	if command == commandDrift || command == commandDiff {
+		if drifted {
+			color.Red("Drift between VCS and cluster found\n")
+			os.Exit(driftExitCode)
+		}
+		color.Green("No drift between VCS and cluster\n")
+		return
+	}
+
+	if err := opts.Backup.Close(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if opts.Plan != nil {
+		if err := opts.Plan.Save(planPath); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
+		color.Cyan("Plan of deleting %d objects is written to %s\n", len(opts.Plan.Objects), planPath)
 	}
+
+	opts.Summary.Print()
 }
 
 func stringInSlice(a string, list []string) bool {
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// maxOwnerDepth limits walking of owner chains
+const maxOwnerDepth = 10
+
+// ControllerOf returns description of controller owner of the given object, empty string if there is no one
+func ControllerOf(object metav1.Object) string {
+	ref := metav1.GetControllerOf(object)
+	if ref == nil {
+		return ""
+	}
+
+	return fmt.Sprintf("%s %s", ref.Kind, ref.Name)
+}
+
+// OwnedBy returns whether the given object has owner with the given UID
+func OwnedBy(object metav1.Object, uid types.UID) bool {
+	for _, ref := range object.GetOwnerReferences() {
+		if ref.UID == uid {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RootOwner walks controller owner chain of the given object up to top-level object. It returns nil root if
+// some owner in chain doesn't exist anymore
+func (c *Client) RootOwner(object *unstructured.Unstructured) (*unstructured.Unstructured, *meta.RESTMapping, error) {
+	root := object
+	var rootMapping *meta.RESTMapping
+
+	for depth := 0; depth < maxOwnerDepth; depth++ {
+		ref := metav1.GetControllerOf(root)
+		if ref == nil {
+			return root, rootMapping, nil
+		}
+
+		gv, err := schema.ParseGroupVersion(ref.APIVersion)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "invalid owner of %s %s", root.GetKind(), root.GetName())
+		}
+		mapping, err := c.mapper.RESTMapping(schema.GroupKind{Group: gv.Group, Kind: ref.Kind}, gv.Version)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to resolve owner kind %s", ref.Kind)
+		}
+
+		namespace := ""
+		if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+			namespace = object.GetNamespace()
+		}
+		owner, err := c.dynamic.Resource(mapping.Resource).Namespace(namespace).Get(ref.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil, nil, nil
+		}
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to retrieve owner %s %s", ref.Kind, ref.Name)
+		}
+		if ref.UID != "" && owner.GetUID() != ref.UID {
+			return nil, nil, nil
+		}
+
+		root, rootMapping = owner, mapping
+	}
+
+	return nil, nil, errors.Errorf("owner chain of %s %s is deeper than %d", object.GetKind(), object.GetName(), maxOwnerDepth)
+}
+
+// RootCleaner deletes root owner of the given controlled object absent in VCS, unless root owner is defined
+// in VCS. Root owner passes the same checks as objects of its own kind and is handled once per run
+func (c *Client) RootCleaner(kind string, object unstructured.Unstructured, inventory *Inventory, opts CleanerOptions) {
+	root, mapping, err := c.RootOwner(&object)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if root == nil {
+		color.Yellow("Skipping %s %s: owner %s doesn't exist anymore", kind, object.GetName(), ControllerOf(&object))
+		return
+	}
+
+	rootKind := mapping.GroupVersionKind.Kind
+	if root.GetNamespace() != object.GetNamespace() {
+		color.Yellow("Skipping %s %s: root owner %s %s is cluster-scoped", kind, object.GetName(), rootKind, root.GetName())
+		return
+	}
+	if opts.Manifests.Has(KeyOf(root)) {
+		color.Yellow("Skipping %s %s: root owner %s %s is defined in VCS", kind, object.GetName(), rootKind, root.GetName())
+		return
+	}
+	if opts.Roots[KeyOf(root)] || opts.Deleted[KeyOf(root)] {
+		return
+	}
+	if opts.Roots != nil {
+		opts.Roots[KeyOf(root)] = true
+	}
+	if opts.Candidates != nil && !opts.Candidates.Has(KeyOf(root)) {
+		color.Yellow("Skipping %s %s: root owner %s %s wasn't removed in revision range", kind, object.GetName(), rootKind, root.GetName())
+		return
+	}
+	if inventory != nil && !inventory.Has(KeyOf(root)) {
+		color.Yellow("Skipping %s %s: root owner %s %s isn't recorded in inventory", kind, object.GetName(), rootKind, root.GetName())
+		return
+	}
+	if detector := DetectOwner(opts.Detectors, root); detector != "" {
+		color.Yellow("Skipping %s %s: root owner %s %s is managed by %s", kind, object.GetName(), rootKind, root.GetName(), detector)
+		opts.Summary.Exclude(detector)
+		return
+	}
+	if reason := opts.Protection.Reason(rootKind, root); reason != "" {
+		color.Red("You can't delete %s %s (root owner of %s %s): %s", rootKind, root.GetName(), kind, object.GetName(), reason)
+		return
+	}
+
+	description := fmt.Sprintf("%s %s (root owner of %s %s)", rootKind, root.GetName(), kind, object.GetName())
+	c.PruneObject(mapping, *root, description, opts, time.Now())
+}
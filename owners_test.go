@@ -0,0 +1,146 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var (
+	deploymentKind = schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	replicaSetKind = schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "ReplicaSet"}
+)
+
+// newTestClient returns client of fake cluster with the given objects of Deployment and ReplicaSet kinds
+func newTestClient(objects []runtime.Object, clusterObjects ...runtime.Object) *Client {
+	scheme := runtime.NewScheme()
+	mapper := meta.NewDefaultRESTMapper(nil)
+	for _, gvk := range []schema.GroupVersionKind{deploymentKind, replicaSetKind} {
+		mapper.Add(gvk, meta.RESTScopeNamespace)
+		scheme.AddKnownTypeWithName(gvk, &unstructured.Unstructured{})
+		scheme.AddKnownTypeWithName(gvk.GroupVersion().WithKind(gvk.Kind+"List"), &unstructured.UnstructuredList{})
+	}
+
+	return &Client{
+		clientset: fake.NewSimpleClientset(objects...),
+		dynamic:   dynamicfake.NewSimpleDynamicClient(scheme, clusterObjects...),
+		mapper:    mapper,
+	}
+}
+
+// newTestObject returns object of the given kind created an hour ago, controlled by owner if it isn't nil
+func newTestObject(gvk schema.GroupVersionKind, name string, owner *unstructured.Unstructured) *unstructured.Unstructured {
+	object := &unstructured.Unstructured{}
+	object.SetGroupVersionKind(gvk)
+	object.SetNamespace("default")
+	object.SetName(name)
+	object.SetUID(types.UID(name + "-uid"))
+	object.SetCreationTimestamp(metav1.NewTime(time.Now().Add(-time.Hour)))
+	if owner != nil {
+		controller := true
+		object.SetOwnerReferences([]metav1.OwnerReference{{
+			APIVersion: owner.GetAPIVersion(),
+			Kind:       owner.GetKind(),
+			Name:       owner.GetName(),
+			UID:        owner.GetUID(),
+			Controller: &controller,
+		}})
+	}
+
+	return object
+}
+
+func TestObjectsCleanerResolvesOwners(t *testing.T) {
+	deployment := newTestObject(deploymentKind, "web", nil)
+	replicaSet := newTestObject(replicaSetKind, "web-5d4b", deployment)
+
+	candidates := func(objects ...*unstructured.Unstructured) *ManifestIndex {
+		index := NewManifestIndex()
+		for _, object := range objects {
+			index.Add(object, "default", Location{})
+		}
+		return index
+	}
+	inventory := func(kinds ...string) *corev1.ConfigMap {
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "inventory", Namespace: "default"},
+			Data:       make(map[string]string),
+		}
+		for _, kind := range kinds {
+			configMap.Data[kind] = ""
+		}
+		return configMap
+	}
+
+	tests := []struct {
+		name        string
+		candidates  *ManifestIndex
+		inventory   *corev1.ConfigMap
+		wantDeleted bool
+	}{
+		{
+			name:        "root owner absent in VCS",
+			wantDeleted: true,
+		},
+		{
+			name:        "root owner removed in revision range",
+			candidates:  candidates(deployment),
+			wantDeleted: true,
+		},
+		{
+			name:       "root owner not removed in revision range",
+			candidates: candidates(),
+		},
+		{
+			name:        "root owner recorded in inventory",
+			inventory:   inventory("Deployment_web"),
+			wantDeleted: true,
+		},
+		{
+			name:      "root owner not recorded in inventory",
+			inventory: inventory("ReplicaSet_web-5d4b"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var objects []runtime.Object
+			opts := CleanerOptions{
+				Manifests:     NewManifestIndex(),
+				Candidates:    test.candidates,
+				ResolveOwners: true,
+				Deleted:       make(map[ObjectKey]bool),
+				Roots:         make(map[ObjectKey]bool),
+			}
+			if test.inventory != nil {
+				objects = append(objects, test.inventory)
+				opts.Inventory = test.inventory.Name
+			}
+			client := newTestClient(objects, deployment.DeepCopy(), replicaSet.DeepCopy())
+			mapping, err := client.mapper.RESTMapping(replicaSetKind.GroupKind(), replicaSetKind.Version)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := client.ObjectsCleaner("default", mapping, opts); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := opts.Deleted[KeyOf(deployment)]; got != test.wantDeleted {
+				t.Errorf("got deleted %v, want %v", got, test.wantDeleted)
+			}
+			if opts.Deleted[KeyOf(replicaSet)] {
+				t.Error("controlled ReplicaSet was deleted instead of its root owner")
+			}
+		})
+	}
+}
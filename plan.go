@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	planAPIVersion = "k8s-cleaner.io/v1alpha1"
+	planKind       = "CleanerPlan"
+)
+
+// Plan represents reviewable list of objects to delete. Apply deletes exactly these objects and refuses objects
+// changed since planning
+type Plan struct {
+	APIVersion string    `json:"apiVersion"`
+	Kind       string    `json:"kind"`
+	CreatedAt  time.Time `json:"createdAt"`
+	// Context is kubeconfig context plan was made in
+	Context    string   `json:"context"`
+	Namespaces []string `json:"namespaces"`
+	// Revisions are VCS revisions of manifest sources
+	Revisions map[string]string `json:"revisions,omitempty"`
+	Objects   []PlanObject      `json:"objects"`
+}
+
+// PlanObject identifies object to delete and its state at planning
+type PlanObject struct {
+	APIVersion      string    `json:"apiVersion"`
+	Kind            string    `json:"kind"`
+	Namespace       string    `json:"namespace"`
+	Name            string    `json:"name"`
+	UID             types.UID `json:"uid"`
+	ResourceVersion string    `json:"resourceVersion"`
+	// Owners are UIDs of owners of object
+	Owners []types.UID `json:"owners,omitempty"`
+}
+
+// NewPlan creates empty plan
+func NewPlan(context string, namespaces []string, revisions map[string]string, now time.Time) *Plan {
+	return &Plan{
+		APIVersion: planAPIVersion,
+		Kind:       planKind,
+		CreatedAt:  now.UTC(),
+		Context:    context,
+		Namespaces: namespaces,
+		Revisions:  revisions,
+		Objects:    []PlanObject{},
+	}
+}
+
+// Add records object of the given API version and kind to delete, nil plan records nothing
+func (p *Plan) Add(object metav1.Object, apiVersion, kind string) {
+	if p == nil {
+		return
+	}
+
+	var owners []types.UID
+	for _, ref := range object.GetOwnerReferences() {
+		owners = append(owners, ref.UID)
+	}
+
+	p.Objects = append(p.Objects, PlanObject{
+		APIVersion:      apiVersion,
+		Kind:            kind,
+		Namespace:       object.GetNamespace(),
+		Name:            object.GetName(),
+		UID:             object.GetUID(),
+		ResourceVersion: object.GetResourceVersion(),
+		Owners:          owners,
+	})
+}
+
+// Save writes plan to the given file as JSON
+func (p *Plan) Save(path string) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to encode plan")
+	}
+
+	return errors.Wrap(ioutil.WriteFile(path, data, 0644), "failed to write plan")
+}
+
+// LoadPlan reads plan from the given file
+func LoadPlan(path string) (*Plan, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read plan")
+	}
+
+	plan := &Plan{}
+	if err := json.Unmarshal(data, plan); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse plan %s", path)
+	}
+	if plan.APIVersion != planAPIVersion || plan.Kind != planKind {
+		return nil, errors.Errorf("%s is not a plan of %s", path, planAPIVersion)
+	}
+
+	return plan, nil
+}
+
+// ApplyPlan deletes objects of the given plan. Objects which were deleted, re-created or modified since planning
+// are skipped. Only UID of dependents of planned objects is compared, since garbage collector orphaning them
+// changes their resourceVersion
+func (c *Client) ApplyPlan(plan *Plan, opts CleanerOptions) error {
+	plannedUIDs := make(map[types.UID]bool, len(plan.Objects))
+	for _, object := range plan.Objects {
+		plannedUIDs[object.UID] = true
+	}
+
+	for _, planned := range plan.Objects {
+		gv, err := schema.ParseGroupVersion(planned.APIVersion)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, errors.Wrapf(err, "invalid API version of %s %s", planned.Kind, planned.Name))
+			os.Exit(1)
+		}
+		mapping, err := c.mapper.RESTMapping(schema.GroupKind{Group: gv.Group, Kind: planned.Kind}, gv.Version)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, errors.Wrapf(err, "failed to resolve kind %s", planned.Kind))
+			os.Exit(1)
+		}
+
+		object, err := c.dynamic.Resource(mapping.Resource).Namespace(planned.Namespace).Get(planned.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			color.Yellow("Skipping %s %s in namespace %s: it doesn't exist anymore", planned.Kind, planned.Name, planned.Namespace)
+			continue
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, errors.Wrapf(err, "failed to retrieve %s %s", planned.Kind, planned.Name))
+			os.Exit(1)
+		}
+		dependent := false
+		for _, owner := range planned.Owners {
+			dependent = dependent || plannedUIDs[owner]
+		}
+		if dependent {
+			object.SetResourceVersion("")
+		}
+		if object.GetUID() != planned.UID || (!dependent && object.GetResourceVersion() != planned.ResourceVersion) {
+			color.Red("You can't delete %s %s in namespace %s: it changed since plan", planned.Kind, planned.Name, planned.Namespace)
+			continue
+		}
+
+		if opts.DryRun {
+			color.Yellow("******************************************************************************")
+			color.Yellow("  Deleting %s %s in namespace %s [dry-run]\n", planned.Kind, planned.Name, planned.Namespace)
+			color.Yellow("******************************************************************************")
+			if opts.ServerDryRun {
+				ReportServerDryRun(planned.Kind, object, c.DeleteObject(mapping, *object, true))
+			}
+			continue
+		}
+
+		color.Red("******************************************************************************")
+		color.Red("  Deleting %s %s in namespace %s\n", planned.Kind, planned.Name, planned.Namespace)
+		color.Red("******************************************************************************")
+		if err := opts.Backup.Write(object); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if err := c.DeleteObject(mapping, *object, false); err != nil {
+			if err := opts.Backup.Discard(object); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			if IsChanged(err) {
+				color.Yellow("%s %s in namespace %s changed since listing, skipped", planned.Kind, planned.Name, planned.Namespace)
+				continue
+			}
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	protectAnnotation = "k8s-cleaner.io/protect"
+	namespaceKind     = "Namespace"
+)
+
+// ProtectRule matches names of protected objects exactly, by glob or by regular expression
+type ProtectRule struct {
+	// Kind of protected objects, any kind if empty
+	Kind string `json:"kind,omitempty"`
+	// Name matches object name exactly
+	Name string `json:"name,omitempty"`
+	// Glob matches object name by shell pattern, e.g. canary-*
+	Glob string `json:"glob,omitempty"`
+	// Regex matches object name by regular expression
+	Regex string `json:"regex,omitempty"`
+
+	regex *regexp.Regexp
+}
+
+// Compile validates patterns of the rule and prepares it for matching
+func (r *ProtectRule) Compile() error {
+	set := 0
+	for _, pattern := range []string{r.Name, r.Glob, r.Regex} {
+		if pattern != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		return errors.New("exactly one of name, glob or regex must be set")
+	}
+
+	if r.Glob != "" {
+		if _, err := filepath.Match(r.Glob, ""); err != nil {
+			return errors.Wrapf(err, "invalid glob %s", r.Glob)
+		}
+	}
+
+	if r.Regex != "" {
+		regex, err := regexp.Compile(r.Regex)
+		if err != nil {
+			return errors.Wrapf(err, "invalid regex %s", r.Regex)
+		}
+		r.regex = regex
+	}
+
+	return nil
+}
+
+// Matches returns whether the rule matches object of the given kind and name
+func (r ProtectRule) Matches(kind, name string) bool {
+	if r.Kind != "" && r.Kind != kind {
+		return false
+	}
+
+	switch {
+	case r.Name != "":
+		return r.Name == name
+	case r.Glob != "":
+		matched, _ := filepath.Match(r.Glob, name)
+		return matched
+	case r.regex != nil:
+		return r.regex.MatchString(name)
+	}
+
+	return false
+}
+
+// String returns the rule in Kind/pattern form
+func (r ProtectRule) String() string {
+	kind := r.Kind
+	if kind == "" {
+		kind = "*"
+	}
+
+	switch {
+	case r.Glob != "":
+		return fmt.Sprintf("%s/%s (glob)", kind, r.Glob)
+	case r.Regex != "":
+		return fmt.Sprintf("%s/%s (regex)", kind, r.Regex)
+	}
+
+	return kind + "/" + r.Name
+}
+
+// Protection decides which objects and namespaces must never be cleaned
+type Protection struct {
+	// Selector matches labels of protected objects and namespaces
+	Selector labels.Selector
+	// Rules match names of protected objects
+	Rules []ProtectRule
+}
+
+// NewProtection creates Protection from label selector and list of protected objects in Kind/name form
+func NewProtection(selector string, objects []string) (Protection, error) {
+	protection := Protection{
+		Selector: labels.Nothing(),
+	}
+
+	if selector != "" {
+		parsed, err := labels.Parse(selector)
+		if err != nil {
+			return protection, errors.Wrapf(err, "invalid protect selector %s", selector)
+		}
+		protection.Selector = parsed
+	}
+
+	for _, object := range objects {
+		parts := strings.SplitN(object, "/", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return protection, errors.Errorf("invalid protected object %s, expected Kind/name", object)
+		}
+		protection.Rules = append(protection.Rules, ProtectRule{Kind: parts[0], Name: parts[1]})
+	}
+
+	return protection, nil
+}
+
+// With returns a copy of protection extended with the given rules
+func (p Protection) With(rules []ProtectRule) Protection {
+	extended := make([]ProtectRule, 0, len(p.Rules)+len(rules))
+	extended = append(extended, p.Rules...)
+	extended = append(extended, rules...)
+
+	return Protection{
+		Selector: p.Selector,
+		Rules:    extended,
+	}
+}
+
+// Reason returns why the given object is protected, empty string if it isn't
+func (p Protection) Reason(kind string, object metav1.Object) string {
+	if object.GetAnnotations()[protectAnnotation] == "true" {
+		return fmt.Sprintf("annotated with %s=true", protectAnnotation)
+	}
+
+	if p.Selector != nil && p.Selector.Matches(labels.Set(object.GetLabels())) {
+		return fmt.Sprintf("labels match protect selector %s", p.Selector)
+	}
+
+	for _, rule := range p.Rules {
+		if rule.Matches(kind, object.GetName()) {
+			return fmt.Sprintf("matches protect rule %s", rule)
+		}
+	}
+
+	return ""
+}
+
+// NamespaceProtection returns why the whole namespace is protected, empty string if it isn't
+func (c *Client) NamespaceProtection(namespace string, protection Protection) (string, error) {
+	ns, err := c.clientset.CoreV1().Namespaces().Get(namespace, metav1.GetOptions{})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to retrieve namespace %s", namespace)
+	}
+
+	return protection.Reason(namespaceKind, ns), nil
+}
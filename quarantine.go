@@ -0,0 +1,160 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	quarantineLabel            = "k8s-cleaner.io/quarantined"
+	markedAtAnnotation         = "k8s-cleaner.io/marked-at"
+	originalReplicasAnnotation = "k8s-cleaner.io/original-replicas"
+	originalSuspendAnnotation  = "k8s-cleaner.io/original-suspend"
+	cancelledAtAnnotation      = "k8s-cleaner.io/quarantine-cancelled-at"
+	// startedAtAnnotation stays on object until it is released, so quarantine is known even if both label
+	// and marked-at annotation are removed
+	startedAtAnnotation = "k8s-cleaner.io/quarantine-started-at"
+)
+
+// quarantineAnnotations are set by quarantine and stay on object until it is released
+var quarantineAnnotations = []string{markedAtAnnotation, startedAtAnnotation, originalReplicasAnnotation, originalSuspendAnnotation}
+
+// QuarantinePolicy makes pruning two-phase: objects absent in VCS are marked and stopped first and deleted
+// by a later run after grace period
+type QuarantinePolicy struct {
+	Enabled bool
+	// Grace is the minimum time object stays in quarantine before deletion
+	Grace time.Duration
+}
+
+// QuarantineCancelled returns whether quarantine of the given object was cancelled by an earlier run
+func QuarantineCancelled(object metav1.Object) bool {
+	_, ok := object.GetAnnotations()[cancelledAtAnnotation]
+
+	return ok
+}
+
+// CancelRequested returns whether label or marked-at annotation of quarantine was removed from the given object.
+// If both are removed, other annotations left by quarantine show that the object was quarantined
+func CancelRequested(object metav1.Object) bool {
+	annotations := object.GetAnnotations()
+	_, marked := annotations[markedAtAnnotation]
+	labelled := object.GetLabels()[quarantineLabel] == "true"
+	if marked || labelled {
+		return marked != labelled
+	}
+
+	for _, annotation := range quarantineAnnotations {
+		if _, ok := annotations[annotation]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// InQuarantine returns whether the given object carries any mark of quarantine, including cancelled one
+func InQuarantine(object metav1.Object) bool {
+	for _, annotation := range quarantineAnnotations {
+		if _, ok := object.GetAnnotations()[annotation]; ok {
+			return true
+		}
+	}
+
+	return QuarantineCancelled(object) || object.GetLabels()[quarantineLabel] != ""
+}
+
+// MarkedAt returns time the given object was put in quarantine and whether it is in quarantine
+func MarkedAt(object metav1.Object) (time.Time, bool) {
+	value, ok := object.GetAnnotations()[markedAtAnnotation]
+	if !ok {
+		return time.Time{}, false
+	}
+
+	markedAt, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return markedAt, true
+}
+
+// Quarantine marks the given object with label and annotation, scales Deployments and StatefulSets to zero
+// and suspends CronJobs. Original state is stored in annotations
+func (c *Client) Quarantine(mapping *meta.RESTMapping, object unstructured.Unstructured, now time.Time) error {
+	annotations := map[string]interface{}{
+		markedAtAnnotation:  now.UTC().Format(time.RFC3339),
+		startedAtAnnotation: now.UTC().Format(time.RFC3339),
+	}
+	patch := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels":      map[string]interface{}{quarantineLabel: "true"},
+			"annotations": annotations,
+		},
+	}
+
+	switch mapping.GroupVersionKind.Kind {
+	case "Deployment", "StatefulSet":
+		replicas, found, _ := unstructured.NestedInt64(object.Object, "spec", "replicas")
+		if !found {
+			replicas = 1
+		}
+		annotations[originalReplicasAnnotation] = strconv.FormatInt(replicas, 10)
+		patch["spec"] = map[string]interface{}{"replicas": 0}
+	case "CronJob":
+		suspend, _, _ := unstructured.NestedBool(object.Object, "spec", "suspend")
+		annotations[originalSuspendAnnotation] = strconv.FormatBool(suspend)
+		patch["spec"] = map[string]interface{}{"suspend": true}
+	}
+
+	return c.PatchObject(mapping, object, patch)
+}
+
+// Release takes the given object out of quarantine, restoring replicas and suspension stored in annotations
+func (c *Client) Release(mapping *meta.RESTMapping, object unstructured.Unstructured) error {
+	return c.PatchObject(mapping, object, releasePatch(object))
+}
+
+// CancelQuarantine releases the given object and records cancellation, so later runs neither quarantine
+// nor delete it while it is absent in VCS
+func (c *Client) CancelQuarantine(mapping *meta.RESTMapping, object unstructured.Unstructured, now time.Time) error {
+	patch := releasePatch(object)
+	metadata := patch["metadata"].(map[string]interface{})
+	metadata["annotations"].(map[string]interface{})[cancelledAtAnnotation] = now.UTC().Format(time.RFC3339)
+
+	return c.PatchObject(mapping, object, patch)
+}
+
+// releasePatch returns merge patch removing marks of quarantine and restoring replicas and suspension
+func releasePatch(object unstructured.Unstructured) map[string]interface{} {
+	patch := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]interface{}{quarantineLabel: nil},
+			"annotations": map[string]interface{}{
+				markedAtAnnotation:         nil,
+				startedAtAnnotation:        nil,
+				originalReplicasAnnotation: nil,
+				originalSuspendAnnotation:  nil,
+				cancelledAtAnnotation:      nil,
+			},
+		},
+	}
+
+	spec := map[string]interface{}{}
+	annotations := object.GetAnnotations()
+	if replicas, err := strconv.ParseInt(annotations[originalReplicasAnnotation], 10, 64); err == nil {
+		spec["replicas"] = replicas
+	}
+	if suspend, err := strconv.ParseBool(annotations[originalSuspendAnnotation]); err == nil {
+		spec["suspend"] = suspend
+	}
+	if len(spec) > 0 {
+		patch["spec"] = spec
+	}
+
+	return patch
+}
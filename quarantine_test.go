@@ -0,0 +1,171 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestCancelRequested(t *testing.T) {
+	tests := []struct {
+		name        string
+		labels      map[string]string
+		annotations map[string]string
+		want        bool
+	}{
+		{
+			name: "never quarantined",
+		},
+		{
+			name:        "in quarantine",
+			labels:      map[string]string{quarantineLabel: "true"},
+			annotations: map[string]string{markedAtAnnotation: "2026-10-18T10:00:00Z", startedAtAnnotation: "2026-10-18T10:00:00Z"},
+		},
+		{
+			name:        "label removed",
+			annotations: map[string]string{markedAtAnnotation: "2026-10-18T10:00:00Z", startedAtAnnotation: "2026-10-18T10:00:00Z"},
+			want:        true,
+		},
+		{
+			name:        "label set to other value",
+			labels:      map[string]string{quarantineLabel: "false"},
+			annotations: map[string]string{markedAtAnnotation: "2026-10-18T10:00:00Z"},
+			want:        true,
+		},
+		{
+			name:        "annotation removed",
+			labels:      map[string]string{quarantineLabel: "true"},
+			annotations: map[string]string{startedAtAnnotation: "2026-10-18T10:00:00Z"},
+			want:        true,
+		},
+		{
+			name:        "label and annotation removed",
+			annotations: map[string]string{startedAtAnnotation: "2026-10-18T10:00:00Z"},
+			want:        true,
+		},
+		{
+			name:        "label and annotations removed except original replicas",
+			annotations: map[string]string{originalReplicasAnnotation: "3"},
+			want:        true,
+		},
+		{
+			name:        "label and annotations removed except original suspension",
+			annotations: map[string]string{originalSuspendAnnotation: "false"},
+			want:        true,
+		},
+		{
+			name:        "cancelled by earlier run",
+			annotations: map[string]string{cancelledAtAnnotation: "2026-10-18T10:00:00Z"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			object := &metav1.ObjectMeta{Labels: test.labels, Annotations: test.annotations}
+			if got := CancelRequested(object); got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestInQuarantine(t *testing.T) {
+	tests := []struct {
+		name        string
+		labels      map[string]string
+		annotations map[string]string
+		want        bool
+	}{
+		{
+			name:        "never quarantined",
+			labels:      map[string]string{"app": "web"},
+			annotations: map[string]string{orphanedSinceAnnotation: "2026-10-18T10:00:00Z"},
+		},
+		{
+			name:   "label",
+			labels: map[string]string{quarantineLabel: "true"},
+			want:   true,
+		},
+		{
+			name:        "marked-at annotation",
+			annotations: map[string]string{markedAtAnnotation: "2026-10-18T10:00:00Z"},
+			want:        true,
+		},
+		{
+			name:        "started-at annotation",
+			annotations: map[string]string{startedAtAnnotation: "2026-10-18T10:00:00Z"},
+			want:        true,
+		},
+		{
+			name:        "original replicas",
+			annotations: map[string]string{originalReplicasAnnotation: "3"},
+			want:        true,
+		},
+		{
+			name:        "cancelled",
+			annotations: map[string]string{cancelledAtAnnotation: "2026-10-18T10:00:00Z"},
+			want:        true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			object := &metav1.ObjectMeta{Labels: test.labels, Annotations: test.annotations}
+			if got := InQuarantine(object); got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestReleasePatch(t *testing.T) {
+	metadata := map[string]interface{}{
+		"labels": map[string]interface{}{quarantineLabel: nil},
+		"annotations": map[string]interface{}{
+			markedAtAnnotation:         nil,
+			startedAtAnnotation:        nil,
+			originalReplicasAnnotation: nil,
+			originalSuspendAnnotation:  nil,
+			cancelledAtAnnotation:      nil,
+		},
+	}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        map[string]interface{}
+	}{
+		{
+			name:        "object without stored state",
+			annotations: map[string]string{markedAtAnnotation: "2026-10-18T10:00:00Z"},
+			want:        map[string]interface{}{"metadata": metadata},
+		},
+		{
+			name:        "replicas are restored",
+			annotations: map[string]string{originalReplicasAnnotation: "3"},
+			want:        map[string]interface{}{"metadata": metadata, "spec": map[string]interface{}{"replicas": int64(3)}},
+		},
+		{
+			name:        "suspension is restored",
+			annotations: map[string]string{originalSuspendAnnotation: "false"},
+			want:        map[string]interface{}{"metadata": metadata, "spec": map[string]interface{}{"suspend": false}},
+		},
+		{
+			name:        "invalid stored state is ignored",
+			annotations: map[string]string{originalReplicasAnnotation: "many", originalSuspendAnnotation: "maybe"},
+			want:        map[string]interface{}{"metadata": metadata},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			object := unstructured.Unstructured{Object: map[string]interface{}{}}
+			object.SetAnnotations(test.annotations)
+			if got := releasePatch(object); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
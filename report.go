@@ -0,0 +1,44 @@
+package main
+
+import (
+	"github.com/fatih/color"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ReportParseErrors prints documents which couldn't be read or decoded
+func ReportParseErrors(errs []ParseError) {
+	if len(errs) == 0 {
+		return
+	}
+
+	color.Red("%d manifest documents couldn't be read or decoded:\n", len(errs))
+	for _, err := range errs {
+		color.Red("  %s\n", err)
+	}
+}
+
+// ReportUnsupportedKinds prints documents skipped because their kind isn't a namespaced kind known to the cluster
+func (c *Client) ReportUnsupportedKinds(index *ManifestIndex) {
+	reasons := make(map[schema.GroupVersionKind]string)
+
+	for _, manifest := range index.Manifests() {
+		gvk := manifest.Object.GroupVersionKind()
+
+		reason, ok := reasons[gvk]
+		if !ok {
+			mapping, err := c.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+			switch {
+			case err != nil:
+				reason = "kind is unknown to the cluster"
+			case mapping.Scope.Name() != meta.RESTScopeNameNamespace:
+				reason = "kind is cluster-scoped"
+			}
+			reasons[gvk] = reason
+		}
+
+		if reason != "" {
+			color.Yellow("Skipping %s %s from %s: %s\n", gvk.Kind, manifest.Object.GetName(), manifest.Location, reason)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"github.com/fatih/color"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// RestoreFilter selects objects of backup to restore
+type RestoreFilter struct {
+	// Namespaces of restored objects, all namespaces if empty
+	Namespaces []string
+	// Kind of restored objects, all kinds if empty
+	Kind string
+	// Matches selects objects by labels, all objects if nil
+	Matches func(object *unstructured.Unstructured) bool
+}
+
+// Selects returns whether the given object has to be restored
+func (f RestoreFilter) Selects(object *unstructured.Unstructured) bool {
+	if len(f.Namespaces) > 0 && !stringInSlice(object.GetNamespace(), f.Namespaces) {
+		return false
+	}
+	if f.Kind != "" && object.GetKind() != f.Kind {
+		return false
+	}
+
+	return f.Matches == nil || f.Matches(object)
+}
+
+// CreateObject creates the given object
+func (c *Client) CreateObject(object *unstructured.Unstructured) error {
+	gvk := object.GroupVersionKind()
+	mapping, err := c.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return errors.Wrapf(err, "failed to resolve kind %s", gvk.Kind)
+	}
+
+	if _, err := c.dynamic.Resource(mapping.Resource).Namespace(object.GetNamespace()).Create(object, metav1.CreateOptions{}); err != nil {
+		return errors.Wrapf(err, "failed to create %s %s", gvk.Kind, object.GetName())
+	}
+
+	return nil
+}
+
+// RestoreObjects re-creates selected objects of backup in the given order, existing objects are skipped. Failure
+// of an object is reported and doesn't stop restoring the rest
+func (c *Client) RestoreObjects(objects []*unstructured.Unstructured, filter RestoreFilter, dryRun bool) error {
+	failed := 0
+	for _, object := range objects {
+		if !filter.Selects(object) {
+			continue
+		}
+
+		if dryRun {
+			color.Yellow("  Restoring %s %s in namespace %s [dry-run]", object.GetKind(), object.GetName(), object.GetNamespace())
+			continue
+		}
+
+		color.Green("  Restoring %s %s in namespace %s", object.GetKind(), object.GetName(), object.GetNamespace())
+		if err := c.CreateObject(object); err != nil {
+			if apierrors.IsAlreadyExists(errors.Cause(err)) {
+				color.Yellow("Skipping %s %s: it already exists", object.GetKind(), object.GetName())
+				continue
+			}
+			color.Red("Failed to restore %s %s in namespace %s: %s", object.GetKind(), object.GetName(), object.GetNamespace(), errors.Cause(err))
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return errors.Errorf("failed to restore %d objects", failed)
+	}
+
+	return nil
+}